@@ -0,0 +1,97 @@
+package mymigrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// nextBatch returns the batch of migrations applied by a new run after history records
+func nextBatch(records []HistoryRecord) int64 {
+	var latest int64
+	for _, record := range records {
+		if record.Batch > latest {
+			latest = record.Batch
+		}
+	}
+
+	return latest + 1
+}
+
+// RollbackBatch reverts migrations of the latest batch, i.e. migrations applied by the latest Apply run
+func (m *Migrator) RollbackBatch() ([]string, error) {
+	return m.RollbackBatchesContext(context.Background(), 1)
+}
+
+// RollbackBatchContext reverts migrations of the latest batch and stops as soon as ctx is done
+func (m *Migrator) RollbackBatchContext(ctx context.Context) ([]string, error) {
+	return m.RollbackBatchesContext(ctx, 1)
+}
+
+// RollbackBatches reverts migrations of particular number of the latest batches
+func (m *Migrator) RollbackBatches(number int) ([]string, error) {
+	return m.RollbackBatchesContext(context.Background(), number)
+}
+
+// RollbackBatchesContext reverts migrations of particular number of the latest batches
+// and stops as soon as ctx is done. Migrations marked as applied by hand and migrations applied
+// before batches were recorded don't belong to any batch and aren't reverted
+func (m *Migrator) RollbackBatchesContext(ctx context.Context, number int) ([]string, error) {
+	return m.runMigrations(ctx, DirectionDown, func() ([]string, []HistoryRecord, error) {
+		return m.namesToRollback(ctx, number)
+	})
+}
+
+// RollbackPlan returns a plan of RollbackBatches
+func (m *Migrator) RollbackPlan(number int) (MigrationPlan, error) {
+	return m.RollbackPlanContext(context.Background(), number)
+}
+
+// RollbackPlanContext returns a plan of RollbackBatchesContext
+func (m *Migrator) RollbackPlanContext(ctx context.Context, number int) (MigrationPlan, error) {
+	err := m.Validate()
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+
+	names, _, err := m.namesToRollback(ctx, number)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+
+	return m.plan(DirectionDown, names), nil
+}
+
+// namesToRollback returns names of migrations of particular number of the latest batches
+// from the latest applied to the earliest one and history records they were chosen from
+func (m *Migrator) namesToRollback(ctx context.Context, number int) ([]string, []HistoryRecord, error) {
+	if number < 1 {
+		return nil, nil, fmt.Errorf("number of batches should be positive, got %d", number)
+	}
+
+	records, err := m.cleanRecords(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	batches := make(map[int64]bool, number)
+	for _, record := range records {
+		if record.Batch == 0 || batches[record.Batch] {
+			continue
+		}
+
+		if len(batches) == number {
+			break
+		}
+
+		batches[record.Batch] = true
+	}
+
+	names := make([]string, 0)
+	for _, record := range records {
+		if batches[record.Batch] {
+			names = append(names, record.Name)
+		}
+	}
+
+	return names, records, m.checkDown(records, names)
+}
@@ -0,0 +1,194 @@
+package mymigrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iamsalnikov/mymigrate/migrationtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// batchedRecords returns history records of migrations with their batches from the latest applied to the earliest one
+func batchedRecords() []HistoryRecord {
+	return []HistoryRecord{
+		{Name: "mig_005", Batch: 3},
+		{Name: "mig_004", Batch: 3},
+		{Name: "mig_003"},
+		{Name: "mig_002", Batch: 2},
+		{Name: "mig_001", Batch: 1},
+	}
+}
+
+func TestMigrator_ApplyRecordsBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	provider := migrationtest.NewMockDbProvider(ctrl)
+	provider.EXPECT().GetDb().AnyTimes()
+	provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	provider.EXPECT().GetApplied(gomock.Any()).Return(batchedRecords(), nil).AnyTimes()
+
+	batches := map[string]int64{}
+	provider.EXPECT().MarkApplied(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, record HistoryRecord) error {
+			batches[record.Name] = record.Batch
+			return nil
+		},
+	).AnyTimes()
+
+	m := NewMigrator(provider)
+	for _, name := range []string{"mig_001", "mig_002", "mig_003", "mig_004", "mig_005", "mig_006", "mig_007"} {
+		m.Add(name, func(db *sql.DB) error { return nil }, nil)
+	}
+
+	applied, err := m.Apply()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mig_006", "mig_007"}, applied)
+	assert.Equal(t, map[string]int64{"mig_006": 4, "mig_007": 4}, batches)
+}
+
+func TestMigrator_RollbackBatches(t *testing.T) {
+	testCases := map[string]struct {
+		history []HistoryRecord
+		number  int
+
+		expectReverted []string
+		expectErr      string
+	}{
+		"the latest batch": {
+			history:        batchedRecords(),
+			number:         1,
+			expectReverted: []string{"mig_005", "mig_004"},
+		},
+		"two batches skip migration without batch": {
+			history:        batchedRecords(),
+			number:         2,
+			expectReverted: []string{"mig_005", "mig_004", "mig_002"},
+		},
+		"more batches than recorded": {
+			history:        batchedRecords(),
+			number:         10,
+			expectReverted: []string{"mig_005", "mig_004", "mig_002", "mig_001"},
+		},
+		"history without batches": {
+			history:        historyRecords("mig_002", "mig_001"),
+			number:         1,
+			expectReverted: []string{},
+		},
+		"non-positive number": {
+			history:   batchedRecords(),
+			number:    0,
+			expectErr: "number of batches should be positive, got 0",
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			provider := migrationtest.NewMockDbProvider(ctrl)
+			provider.EXPECT().GetDb().AnyTimes()
+			provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+			provider.EXPECT().GetApplied(gomock.Any()).Return(tc.history, nil).AnyTimes()
+			provider.EXPECT().MarkApplied(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			for _, name := range tc.expectReverted {
+				provider.EXPECT().DeleteApplied(gomock.Any(), name).Return(nil)
+			}
+
+			m := NewMigrator(provider)
+			for _, name := range []string{"mig_001", "mig_002", "mig_003", "mig_004", "mig_005"} {
+				m.Add(name, func(db *sql.DB) error { return nil }, func(db *sql.DB) error { return nil })
+			}
+
+			reverted, err := m.RollbackBatches(tc.number)
+			if len(tc.expectErr) > 0 {
+				assert.EqualError(t, err, tc.expectErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectReverted, reverted)
+		})
+	}
+}
+
+func TestMigrator_RollbackPlan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	provider := migrationtest.NewMockDbProvider(ctrl)
+	provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	provider.EXPECT().GetApplied(gomock.Any()).Return(batchedRecords(), nil)
+
+	m := NewMigrator(provider)
+	for _, name := range []string{"mig_001", "mig_002", "mig_003", "mig_004", "mig_005"} {
+		m.Add(name, func(db *sql.DB) error { return nil }, func(db *sql.DB) error { return nil })
+	}
+
+	plan, err := m.RollbackPlan(1)
+	assert.NoError(t, err)
+	assert.Equal(t, DirectionDown, plan.Direction)
+	assert.Equal(t, []string{"mig_005", "mig_004"}, plan.Names())
+}
+
+func TestMigrator_RollbackForcedMigration(t *testing.T) {
+	downErr := errors.New("down error")
+	history := []HistoryRecord{
+		{Name: "mig_002", Direction: string(DirectionUp), Batch: 2},
+		{Name: "mig_001", Direction: string(DirectionUp), Batch: 1},
+	}
+
+	ctrl := gomock.NewController(t)
+	provider := migrationtest.NewMockDbProvider(ctrl)
+	provider.EXPECT().GetDb().AnyTimes()
+	provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	provider.EXPECT().GetApplied(gomock.Any()).DoAndReturn(
+		func(ctx context.Context) ([]HistoryRecord, error) {
+			return append([]HistoryRecord{}, history...), nil
+		},
+	).AnyTimes()
+	provider.EXPECT().MarkApplied(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, record HistoryRecord) error {
+			for i := range history {
+				if history[i].Name == record.Name {
+					history[i] = record
+					return nil
+				}
+			}
+
+			history = append([]HistoryRecord{record}, history...)
+			return nil
+		},
+	).AnyTimes()
+	provider.EXPECT().DeleteApplied(gomock.Any(), "mig_002").DoAndReturn(
+		func(ctx context.Context, name string) error {
+			history = history[1:]
+			return nil
+		},
+	)
+
+	m := NewMigrator(provider)
+	m.Add("mig_001", func(db *sql.DB) error { return nil }, func(db *sql.DB) error { return nil })
+	failDown := true
+	m.Add(
+		"mig_002",
+		func(db *sql.DB) error { return nil },
+		func(db *sql.DB) error {
+			if failDown {
+				return downErr
+			}
+
+			return nil
+		},
+	)
+
+	_, err := m.Down(1)
+	assert.Equal(t, downErr, err)
+
+	// the migration was reverted by hand and is forced back to applied, so it is still in the latest batch
+	failDown = false
+	assert.NoError(t, m.ForceApplied("mig_002"))
+	assert.Equal(t, int64(2), history[0].Batch)
+
+	reverted, err := m.RollbackBatch()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mig_002"}, reverted)
+}
@@ -14,9 +14,45 @@ var ApplyCmd = &cobra.Command{
 	RunE:  ApplyRunE,
 }
 
+func init() {
+	ApplyCmd.Flags().String("to", "", "apply new migrations up to and including this one")
+	ApplyCmd.Flags().Bool("dry-run", false, "print migrations that would be applied without applying them")
+	ApplyCmd.Flags().String("out-of-order", "", "what to do with new migrations older than the latest applied one: fail, warn or allow")
+}
+
 // ApplyRunE is a cobra run function for ApplyCmd command
 func ApplyRunE(cmd *cobra.Command, args []string) error {
-	list, err := mymigrate.Apply()
+	var list []string
+
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	err = setupOutOfOrderPolicy(cmd)
+	if err != nil {
+		return err
+	}
+
+	setupLogger(cmd)
+
+	result := newResult(cmd)
+	target := flagValue(cmd, "to")
+	if isDryRun(cmd) {
+		return applyDryRun(cmd, format, result, target)
+	}
+
+	if len(target) > 0 {
+		list, err = mymigrate.ApplyToContext(commandContext(cmd), target)
+	} else {
+		list, err = mymigrate.ApplyContext(commandContext(cmd))
+	}
+
+	if format != outputText {
+		result.Migrations = namesResult(list)
+		return writeResult(cmd, format, result, err)
+	}
+
 	if err != nil {
 		return err
 	}
@@ -34,3 +70,48 @@ func ApplyRunE(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func applyDryRun(cmd *cobra.Command, format string, result *Result, target string) error {
+	var plan mymigrate.MigrationPlan
+	var err error
+
+	if len(target) > 0 {
+		plan, err = mymigrate.PlanToContext(commandContext(cmd), target)
+	} else {
+		plan, err = mymigrate.PlanContext(commandContext(cmd))
+	}
+
+	if format != outputText {
+		result.DryRun = true
+		result.Migrations = planResult(plan)
+		return writeResult(cmd, format, result, err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(plan.Steps) == 0 {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "There are no new migrations")
+
+		return nil
+	}
+
+	printPlan(cmd, plan)
+
+	return nil
+}
+
+// setupOutOfOrderPolicy makes the migrator use the policy passed with --out-of-order flag
+func setupOutOfOrderPolicy(cmd *cobra.Command) error {
+	policy := mymigrate.OutOfOrderPolicy(flagValue(cmd, "out-of-order"))
+	switch policy {
+	case "":
+		return nil
+	case mymigrate.OutOfOrderFail, mymigrate.OutOfOrderWarn, mymigrate.OutOfOrderAllow:
+		mymigrate.SetOutOfOrderPolicy(policy)
+		return nil
+	}
+
+	return fmt.Errorf("unknown out-of-order policy '%s'", policy)
+}
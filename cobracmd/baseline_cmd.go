@@ -0,0 +1,57 @@
+package cobracmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/iamsalnikov/mymigrate"
+	"github.com/spf13/cobra"
+)
+
+// BaselineCmd is a cobra command that marks migrations as applied without running them
+var BaselineCmd = &cobra.Command{
+	Use:   "baseline --to NAME",
+	Short: "mark migrations up to and including NAME as applied without running them",
+	RunE:  BaselineRunE,
+}
+
+func init() {
+	BaselineCmd.Flags().String("to", "", "mark migrations up to and including this one")
+}
+
+// BaselineRunE is a cobra run function for BaselineCmd command
+func BaselineRunE(cmd *cobra.Command, args []string) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	target := flagValue(cmd, "to")
+	if len(target) == 0 {
+		return errors.New("please, pass a migration name with --to flag")
+	}
+
+	result := newResult(cmd)
+	list, err := mymigrate.BaselineContext(commandContext(cmd), target)
+	if format != outputText {
+		result.Migrations = statesResult(list, "applied")
+		return writeResult(cmd, format, result, err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(list) == 0 {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "There is nothing to mark")
+
+		return nil
+	}
+
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), "List of migrations marked as applied:")
+	for _, mig := range list {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), mig)
+	}
+
+	return nil
+}
@@ -1,6 +1,12 @@
 package cobracmd
 
-import "github.com/spf13/cobra"
+import (
+	"context"
+	"log"
+
+	"github.com/iamsalnikov/mymigrate"
+	"github.com/spf13/cobra"
+)
 
 // MigrateCmd is a cobra command to work with migrations
 var MigrateCmd = &cobra.Command{
@@ -9,5 +15,34 @@ var MigrateCmd = &cobra.Command{
 }
 
 func init() {
-	MigrateCmd.AddCommand(CreateCmd, HistoryCmd, NewListCmd, ApplyCmd, DownCmd)
+	MigrateCmd.PersistentFlags().String("output", outputText, "output format: text, json or yaml")
+	MigrateCmd.PersistentFlags().Bool("verbose", false, "log migration runs and SQL statements to stderr")
+	MigrateCmd.AddCommand(CreateCmd, HistoryCmd, NewListCmd, ApplyCmd, DownCmd, RedoCmd, RollbackCmd, StatusCmd, VerifyCmd, ForceCmd, BaselineCmd,
+		MarkAppliedCmd, UnmarkCmd, GraphCmd)
+}
+
+// commandContext returns context of the command or background context if the command was run without it
+func commandContext(cmd *cobra.Command) context.Context {
+	if ctx := cmd.Context(); ctx != nil {
+		return ctx
+	}
+
+	return context.Background()
+}
+
+// setupLogger makes the migrator log runs to stderr of the command if --verbose flag is set
+func setupLogger(cmd *cobra.Command) {
+	if flagValue(cmd, "verbose") == "true" {
+		mymigrate.SetLogger(mymigrate.NewStdLogger(log.New(cmd.ErrOrStderr(), "", log.LstdFlags), true))
+	}
+}
+
+// flagValue returns string value of the command flag or empty string if there is no such flag
+func flagValue(cmd *cobra.Command, name string) string {
+	flag := cmd.Flag(name)
+	if flag == nil || flag.Value == nil {
+		return ""
+	}
+
+	return flag.Value.String()
 }
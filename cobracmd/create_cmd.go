@@ -21,12 +21,40 @@ var CreateCmd = &cobra.Command{
 func init() {
 	CreateCmd.Flags().String("package", "migrations", "name of migratins package")
 	CreateCmd.Flags().String("path", "", "path to migrations dir")
+	CreateCmd.Flags().Bool("sql", false, "create SQL migration file instead of golang one")
 }
 
 // CreateRunE is a cobra run function to create new migration file
 func CreateRunE(cmd *cobra.Command, args []string) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	result := newResult(cmd)
+	migFilePath, name, err := createMigration(cmd, args)
+	if format != outputText {
+		result.File = migFilePath
+		if len(name) > 0 {
+			result.Migrations = namesResult([]string{name})
+		}
+
+		return writeResult(cmd, format, result, err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "New migration file is here: %s\n", migFilePath)
+
+	return nil
+}
+
+// createMigration creates a new migration file and returns its path and the migration name
+func createMigration(cmd *cobra.Command, args []string) (string, string, error) {
 	if len(args) != 1 {
-		return errors.New("please, pass migration name as an argument")
+		return "", "", errors.New("please, pass migration name as an argument")
 	}
 
 	packageName := "migrations"
@@ -38,7 +66,7 @@ func CreateRunE(cmd *cobra.Command, args []string) error {
 
 	basePath, err := os.Getwd()
 	if err != nil {
-		return err
+		return "", "", err
 	}
 
 	path := ""
@@ -54,22 +82,28 @@ func CreateRunE(cmd *cobra.Command, args []string) error {
 	dirpath := filepath.Join(path, packageName)
 	err = os.MkdirAll(dirpath, 0766)
 	if err != nil {
-		return err
+		return "", "", err
 	}
 
 	template, filename := mymigrate.Template(packageName, args[0])
-	migFilePath := filepath.Join(dirpath, filename+".go")
+	extension := ".go"
+
+	sqlFlag := cmd.Flag("sql")
+	if sqlFlag != nil && sqlFlag.Value != nil && sqlFlag.Value.String() == "true" {
+		template, filename = mymigrate.SQLTemplate(args[0])
+		extension = ".sql"
+	}
+
+	migFilePath := filepath.Join(dirpath, filename+extension)
 	f, err := os.Create(migFilePath)
 	if err != nil {
-		return err
+		return "", "", err
 	}
 	defer f.Close()
 	_, err = f.WriteString(template)
 	if err != nil {
-		return err
+		return "", "", err
 	}
 
-	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "New migration file is here: %s\n", migFilePath)
-
-	return nil
+	return migFilePath, filename, nil
 }
@@ -210,3 +210,28 @@ func TestCreateRunE_Out(t *testing.T) {
 	assert.Contains(t, outStr, "New migration file is here:")
 	assert.Contains(t, outStr, "hello.go")
 }
+
+func TestCreateRunE_CreatesSQLMigrationFile(t *testing.T) {
+	out := bytes.NewBufferString("")
+
+	cmd := &cobra.Command{}
+	cmd.SetOut(out)
+	cmd.Flags().AddFlag(&pflag.Flag{
+		Name:  "package",
+		Value: StringValue{Value: "sqlmigrations"},
+	})
+	cmd.Flags().AddFlag(&pflag.Flag{
+		Name:  "sql",
+		Value: StringValue{Value: "true"},
+	})
+
+	err := CreateRunE(cmd, []string{"hello"})
+	assert.Nil(t, err)
+
+	wd, _ := os.Getwd()
+	defer os.RemoveAll(filepath.Join(wd, "sqlmigrations"))
+
+	files, _ := filepath.Glob(filepath.Join(wd, "sqlmigrations", "*-hello.sql"))
+	assert.Len(t, files, 1)
+	assert.Contains(t, out.String(), "hello.sql")
+}
@@ -16,18 +16,31 @@ var DownCmd = &cobra.Command{
 	RunE:  DownRunE,
 }
 
+func init() {
+	DownCmd.Flags().String("to", "", "down all migrations applied after this one")
+	DownCmd.Flags().Bool("dry-run", false, "print migrations that would be downed without downing them")
+}
+
 // DownRunE is a cobra run function for DownCmd command
 func DownRunE(cmd *cobra.Command, args []string) error {
-	if len(args) != 1 {
-		return errors.New("please pass count of migrations to down")
-	}
-
-	number, err := strconv.Atoi(args[0])
+	format, err := outputFormat(cmd)
 	if err != nil {
 		return err
 	}
 
-	list, err := mymigrate.Down(number)
+	setupLogger(cmd)
+
+	result := newResult(cmd)
+	if isDryRun(cmd) {
+		return downDryRun(cmd, format, result, args)
+	}
+
+	list, err := down(cmd, args)
+	if format != outputText {
+		result.Migrations = namesResult(list)
+		return writeResult(cmd, format, result, err)
+	}
+
 	if err != nil {
 		return err
 	}
@@ -45,3 +58,71 @@ func DownRunE(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func downDryRun(cmd *cobra.Command, format string, result *Result, args []string) error {
+	plan, err := downPlan(cmd, args)
+	if format != outputText {
+		result.DryRun = true
+		result.Migrations = planResult(plan)
+		return writeResult(cmd, format, result, err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(plan.Steps) == 0 {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "There is nothing to down")
+
+		return nil
+	}
+
+	printPlan(cmd, plan)
+
+	return nil
+}
+
+func downPlan(cmd *cobra.Command, args []string) (mymigrate.MigrationPlan, error) {
+	target, number, err := downTarget(cmd, args)
+	if err != nil {
+		return mymigrate.MigrationPlan{}, err
+	}
+
+	if len(target) > 0 {
+		return mymigrate.DownToPlanContext(commandContext(cmd), target)
+	}
+
+	return mymigrate.DownPlanContext(commandContext(cmd), number)
+}
+
+func down(cmd *cobra.Command, args []string) ([]string, error) {
+	target, number, err := downTarget(cmd, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(target) > 0 {
+		return mymigrate.DownToContext(commandContext(cmd), target)
+	}
+
+	return mymigrate.DownContext(commandContext(cmd), number)
+}
+
+// downTarget returns either a migration name from --to flag or count of migrations to down
+func downTarget(cmd *cobra.Command, args []string) (string, int, error) {
+	target := flagValue(cmd, "to")
+	if len(target) > 0 {
+		return target, 0, nil
+	}
+
+	if len(args) != 1 {
+		return "", 0, errors.New("please pass count of migrations to down or a migration name with --to flag")
+	}
+
+	number, err := strconv.Atoi(args[0])
+	if err != nil {
+		return "", 0, err
+	}
+
+	return "", number, nil
+}
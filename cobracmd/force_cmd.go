@@ -0,0 +1,58 @@
+package cobracmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/iamsalnikov/mymigrate"
+	"github.com/spf13/cobra"
+)
+
+// ForceCmd is a cobra command that resolves a dirty migration by hand
+var ForceCmd = &cobra.Command{
+	Use:   "force NAME",
+	Short: "mark a migration as applied or reverted without running it",
+	RunE:  ForceRunE,
+}
+
+func init() {
+	ForceCmd.Flags().Bool("applied", false, "mark the migration as applied")
+	ForceCmd.Flags().Bool("reverted", false, "delete the migration from the history")
+}
+
+// ForceRunE is a cobra run function for ForceCmd command.
+// It does the same as MarkAppliedCmd or UnmarkCmd depending on the passed flag
+func ForceRunE(cmd *cobra.Command, args []string) error {
+	state, f, err := forceAction(cmd, args)
+	if err != nil {
+		f = func(context.Context, string) error {
+			return err
+		}
+	}
+
+	name := ""
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	return mark(cmd, name, state, f)
+}
+
+// forceAction returns the new state of the migration and the function that changes it according to the flags
+func forceAction(cmd *cobra.Command, args []string) (string, markFunc, error) {
+	if len(args) != 1 {
+		return "", nil, errors.New("please, pass migration name as an argument")
+	}
+
+	applied := flagValue(cmd, "applied") == "true"
+	reverted := flagValue(cmd, "reverted") == "true"
+	if applied == reverted {
+		return "", nil, errors.New("please, pass either --applied or --reverted flag")
+	}
+
+	if applied {
+		return "applied", mymigrate.ForceAppliedContext, nil
+	}
+
+	return "reverted", mymigrate.ForceRevertedContext, nil
+}
@@ -0,0 +1,63 @@
+package cobracmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForceAction(t *testing.T) {
+	testCases := map[string]struct {
+		args  []string
+		flags []string
+
+		expState string
+		expErr   string
+	}{
+		"applied": {
+			args:     []string{"mig_001"},
+			flags:    []string{"applied"},
+			expState: "applied",
+		},
+		"reverted": {
+			args:     []string{"mig_001"},
+			flags:    []string{"reverted"},
+			expState: "reverted",
+		},
+		"no name": {
+			flags:  []string{"applied"},
+			expErr: "please, pass migration name as an argument",
+		},
+		"no flags": {
+			args:   []string{"mig_001"},
+			expErr: "please, pass either --applied or --reverted flag",
+		},
+		"both flags": {
+			args:   []string{"mig_001"},
+			flags:  []string{"applied", "reverted"},
+			expErr: "please, pass either --applied or --reverted flag",
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			cmd.Flags().Bool("applied", false, "")
+			cmd.Flags().Bool("reverted", false, "")
+			for _, flag := range tc.flags {
+				assert.NoError(t, cmd.Flags().Set(flag, "true"))
+			}
+
+			state, f, err := forceAction(cmd, tc.args)
+			if len(tc.expErr) > 0 {
+				assert.EqualError(t, err, tc.expErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expState, state)
+			assert.NotNil(t, f)
+		})
+	}
+}
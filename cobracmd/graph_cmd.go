@@ -0,0 +1,68 @@
+package cobracmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/iamsalnikov/mymigrate"
+	"github.com/spf13/cobra"
+)
+
+// graphDot is a graph format of Graphviz
+const graphDot = "dot"
+
+// GraphCmd is a cobra command that prints dependencies of registered migrations
+var GraphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "shows dependencies of registered migrations",
+	RunE:  GraphRunE,
+}
+
+func init() {
+	GraphCmd.Flags().String("format", graphDot, "graph format: dot")
+}
+
+// GraphRunE is a cobra run function for GraphCmd command
+func GraphRunE(cmd *cobra.Command, args []string) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	graphFormat := flagValue(cmd, "format")
+	if graphFormat != graphDot {
+		return fmt.Errorf("unknown graph format '%s'", graphFormat)
+	}
+
+	result := newResult(cmd)
+	dependencies := mymigrate.Dependencies()
+	names := make([]string, 0, len(dependencies))
+	for name := range dependencies {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	if format != outputText {
+		for _, name := range names {
+			result.Migrations = append(result.Migrations, MigrationResult{
+				Name:      name,
+				DependsOn: dependencies[name],
+			})
+		}
+
+		return writeResult(cmd, format, result, nil)
+	}
+
+	// edges go from a dependency to the migration that depends on it, i.e. in the order of applying
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), "digraph migrations {")
+	for _, name := range names {
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "  %q;\n", name)
+		for _, dep := range dependencies[name] {
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "  %q -> %q;\n", dep, name)
+		}
+	}
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), "}")
+
+	return nil
+}
@@ -2,34 +2,128 @@ package cobracmd
 
 import (
 	"fmt"
+	"text/tabwriter"
 
 	"github.com/iamsalnikov/mymigrate"
 	"github.com/spf13/cobra"
 )
 
-// HistoryCmd is a cobra command that prints list of applied migrations
+// HistoryCmd is a cobra command that prints list of applied migrations grouped by batch
 var HistoryCmd = &cobra.Command{
 	Use:   "history",
 	Short: "shows list of applied migrations",
 	RunE:  HistoryRunE,
 }
 
+func init() {
+	HistoryCmd.Flags().Bool("details", false, "show when, where, by whom and how long migrations were applied")
+}
+
 // HistoryRunE is a cobra run function for HistoryCmd command
 func HistoryRunE(cmd *cobra.Command, args []string) error {
-	list, err := mymigrate.History()
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	result := newResult(cmd)
+	records, err := mymigrate.HistoryContext(commandContext(cmd))
+	if format != outputText {
+		for _, record := range records {
+			result.Migrations = append(result.Migrations, MigrationResult{
+				Name:       record.Name,
+				AppliedAt:  timeResult(record.AppliedAt),
+				Checksum:   record.Checksum,
+				DurationMs: record.Duration.Milliseconds(),
+				Hostname:   record.Hostname,
+				OSUser:     record.OSUser,
+				AppVersion: record.AppVersion,
+				Direction:  record.Direction,
+				Error:      record.Error,
+				Batch:      record.Batch,
+				Sequence:   record.Sequence,
+			})
+		}
+
+		return writeResult(cmd, format, result, err)
+	}
+
 	if err != nil {
 		return err
 	}
 
-	if len(list) == 0 {
+	if len(records) == 0 {
 		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "History is empty")
 
 		return nil
 	}
 
-	for _, mig := range list {
-		_, _ = fmt.Fprintln(cmd.OutOrStdout(), mig)
+	return printHistory(cmd, records, flagValue(cmd, "details") == "true")
+}
+
+// printHistory prints history records grouped by batch, as tables if details are requested
+func printHistory(cmd *cobra.Command, records []mymigrate.HistoryRecord, details bool) error {
+	for i, group := range batchGroups(records) {
+		if i > 0 && details {
+			_, _ = fmt.Fprintln(cmd.OutOrStdout())
+		}
+
+		if group[0].Batch == 0 {
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Without batch:")
+		} else {
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Batch %d:\n", group[0].Batch)
+		}
+
+		if details {
+			err := printHistoryDetails(cmd, group)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		for _, record := range group {
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), record.Name)
+		}
 	}
 
 	return nil
 }
+
+// batchGroups splits history records into groups of consecutive records of the same batch
+func batchGroups(records []mymigrate.HistoryRecord) [][]mymigrate.HistoryRecord {
+	groups := make([][]mymigrate.HistoryRecord, 0)
+	for i, record := range records {
+		if i == 0 || record.Batch != records[i-1].Batch {
+			groups = append(groups, []mymigrate.HistoryRecord{})
+		}
+
+		groups[len(groups)-1] = append(groups[len(groups)-1], record)
+	}
+
+	return groups
+}
+
+// printHistoryDetails prints history records as a table
+func printHistoryDetails(cmd *cobra.Command, records []mymigrate.HistoryRecord) error {
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SEQUENCE\tNAME\tAPPLIED AT\tDURATION\tHOST\tUSER\tAPP VERSION\tDIRECTION\tERROR")
+	for _, record := range records {
+		_, _ = fmt.Fprintf(
+			w,
+			"%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			record.Sequence,
+			record.Name,
+			record.AppliedAt.Format("2006-01-02 15:04:05 MST"),
+			record.Duration,
+			record.Hostname,
+			record.OSUser,
+			record.AppVersion,
+			record.Direction,
+			record.Error,
+		)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,71 @@
+package cobracmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/iamsalnikov/mymigrate"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchGroups(t *testing.T) {
+	records := []mymigrate.HistoryRecord{
+		{Name: "mig_005", Batch: 3},
+		{Name: "mig_004", Batch: 3},
+		{Name: "mig_003"},
+		{Name: "mig_002", Batch: 2},
+		{Name: "mig_001", Batch: 2},
+		{Name: "mig_000"},
+	}
+
+	assert.Equal(t, [][]mymigrate.HistoryRecord{
+		{records[0], records[1]},
+		{records[2]},
+		{records[3], records[4]},
+		{records[5]},
+	}, batchGroups(records))
+	assert.Empty(t, batchGroups(nil))
+}
+
+func TestPrintHistory(t *testing.T) {
+	appliedAt := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	records := []mymigrate.HistoryRecord{
+		{Name: "mig_003", AppliedAt: appliedAt, Duration: time.Second, Direction: "up", Batch: 2, Sequence: 3},
+		{Name: "mig_002", AppliedAt: appliedAt, Duration: time.Second, Direction: "up", Batch: 2, Sequence: 2},
+		{Name: "mig_001", AppliedAt: appliedAt, Direction: "up", Sequence: 1},
+	}
+
+	testCases := map[string]struct {
+		details bool
+
+		expOut string
+	}{
+		"names": {
+			expOut: "Batch 2:\nmig_003\nmig_002\nWithout batch:\nmig_001\n",
+		},
+		"details": {
+			details: true,
+			expOut: "Batch 2:\n" +
+				"SEQUENCE  NAME     APPLIED AT               DURATION  HOST  USER  APP VERSION  DIRECTION  ERROR\n" +
+				"3         mig_003  2021-03-04 05:06:07 UTC  1s                                 up         \n" +
+				"2         mig_002  2021-03-04 05:06:07 UTC  1s                                 up         \n" +
+				"\n" +
+				"Without batch:\n" +
+				"SEQUENCE  NAME     APPLIED AT               DURATION  HOST  USER  APP VERSION  DIRECTION  ERROR\n" +
+				"1         mig_001  2021-03-04 05:06:07 UTC  0s                                 up         \n",
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			out := bytes.NewBufferString("")
+			cmd := &cobra.Command{}
+			cmd.SetOut(out)
+
+			assert.NoError(t, printHistory(cmd, records, tc.details))
+			assert.Equal(t, tc.expOut, out.String())
+		})
+	}
+}
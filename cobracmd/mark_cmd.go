@@ -0,0 +1,65 @@
+package cobracmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iamsalnikov/mymigrate"
+	"github.com/spf13/cobra"
+)
+
+// MarkAppliedCmd is a cobra command that records a migration to the history without running it
+var MarkAppliedCmd = &cobra.Command{
+	Use:   "mark-applied NAME",
+	Short: "mark a migration as applied without running it",
+	Args:  cobra.ExactArgs(1),
+	RunE:  MarkAppliedRunE,
+}
+
+// UnmarkCmd is a cobra command that deletes a migration from the history without running it
+var UnmarkCmd = &cobra.Command{
+	Use:   "unmark NAME",
+	Short: "delete a migration from the history without running it",
+	Args:  cobra.ExactArgs(1),
+	RunE:  UnmarkRunE,
+}
+
+// MarkAppliedRunE is a cobra run function for MarkAppliedCmd command
+func MarkAppliedRunE(cmd *cobra.Command, args []string) error {
+	return mark(cmd, args[0], "applied", mymigrate.ForceAppliedContext)
+}
+
+// UnmarkRunE is a cobra run function for UnmarkCmd command
+func UnmarkRunE(cmd *cobra.Command, args []string) error {
+	return mark(cmd, args[0], "reverted", mymigrate.ForceRevertedContext)
+}
+
+// markFunc changes state of the named migration without running it
+type markFunc func(ctx context.Context, name string) error
+
+// mark changes state of the named migration with f and prints the new state.
+// It is shared by MarkAppliedCmd, UnmarkCmd and ForceCmd, so they report the change the same way
+func mark(cmd *cobra.Command, name, state string, f markFunc) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	result := newResult(cmd)
+	err = f(commandContext(cmd), name)
+	if format != outputText {
+		if err == nil {
+			result.Migrations = statesResult([]string{name}, state)
+		}
+
+		return writeResult(cmd, format, result, err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Migration %s is marked as %s\n", name, state)
+
+	return nil
+}
@@ -16,7 +16,18 @@ var NewListCmd = &cobra.Command{
 
 // NewListRunE is a cobra run function for NewListCmd command
 func NewListRunE(cmd *cobra.Command, args []string) error {
-	list, err := mymigrate.NewNames()
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	result := newResult(cmd)
+	list, err := mymigrate.NewNamesContext(commandContext(cmd))
+	if format != outputText {
+		result.Migrations = namesResult(list)
+		return writeResult(cmd, format, result, err)
+	}
+
 	if err != nil {
 		return err
 	}
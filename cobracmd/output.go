@@ -0,0 +1,180 @@
+package cobracmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/iamsalnikov/mymigrate"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// outputText is the default human readable output
+	outputText = "text"
+	// outputJSON prints Result as JSON
+	outputJSON = "json"
+	// outputYAML prints Result as YAML
+	outputYAML = "yaml"
+)
+
+const (
+	// ResultOK is a status of a command finished successfully
+	ResultOK = "ok"
+	// ResultError is a status of a command finished with an error
+	ResultError = "error"
+)
+
+// Result is a structured result of a command printed with --output json or --output yaml
+type Result struct {
+	// Command is a name of the command, e.g. "apply"
+	Command string `json:"command" yaml:"command"`
+	// Status is either "ok" or "error"
+	Status string `json:"status" yaml:"status"`
+	// ExitCode is 0 if the command finished successfully and 1 otherwise
+	ExitCode int `json:"exit_code" yaml:"exit_code"`
+	// Error is a message of the error the command finished with
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+	// DryRun tells whether migrations were only planned
+	DryRun bool `json:"dry_run,omitempty" yaml:"dry_run,omitempty"`
+	// StartedAt is the time when the command was started
+	StartedAt time.Time `json:"started_at" yaml:"started_at"`
+	// DurationMs is duration of the command in milliseconds
+	DurationMs int64 `json:"duration_ms" yaml:"duration_ms"`
+	// File is a path of the created migration file
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Migrations that were processed by the command in the order of processing
+	Migrations []MigrationResult `json:"migrations" yaml:"migrations"`
+}
+
+// MigrationResult describes a migration of a command result
+type MigrationResult struct {
+	Name string `json:"name" yaml:"name"`
+	// State is a migration state for status command or a kind of drift for verify command
+	State string `json:"state,omitempty" yaml:"state,omitempty"`
+	// AppliedAt is the time when the migration was applied
+	AppliedAt *time.Time `json:"applied_at,omitempty" yaml:"applied_at,omitempty"`
+	// Transactional tells whether the planned migration runs inside a transaction
+	Transactional bool `json:"transactional,omitempty" yaml:"transactional,omitempty"`
+	// Irreversible tells whether the registered migration can't be reverted
+	Irreversible bool `json:"irreversible,omitempty" yaml:"irreversible,omitempty"`
+	// Statements of the planned SQL migration
+	Statements []string `json:"statements,omitempty" yaml:"statements,omitempty"`
+	// RecordedChecksum is the checksum stored in the history
+	RecordedChecksum string `json:"recorded_checksum,omitempty" yaml:"recorded_checksum,omitempty"`
+	// Checksum is the checksum of the registered migration
+	Checksum string `json:"checksum,omitempty" yaml:"checksum,omitempty"`
+	// DurationMs is how long the applied migration was running in milliseconds
+	DurationMs int64 `json:"duration_ms,omitempty" yaml:"duration_ms,omitempty"`
+	// Hostname is a name of the host the migration was applied on
+	Hostname string `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	// OSUser is a name of the OS user the migration was applied by
+	OSUser string `json:"os_user,omitempty" yaml:"os_user,omitempty"`
+	// AppVersion is a version of the application that applied the migration
+	AppVersion string `json:"app_version,omitempty" yaml:"app_version,omitempty"`
+	// Direction is "up" for applied migrations and "down" for reverted migrations or migrations that failed to revert
+	Direction string `json:"direction,omitempty" yaml:"direction,omitempty"`
+	// Error is a text of the error the dirty migration failed with
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+	// Batch is a number of the apply run the migration was applied by
+	Batch int64 `json:"batch,omitempty" yaml:"batch,omitempty"`
+	// Sequence is a number of the applied migration in the order of application
+	Sequence int64 `json:"sequence,omitempty" yaml:"sequence,omitempty"`
+	// DependsOn holds names of migrations the registered migration depends on
+	DependsOn []string `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+}
+
+// outputFormat returns value of --output flag
+func outputFormat(cmd *cobra.Command) (string, error) {
+	format := flagValue(cmd, "output")
+	switch format {
+	case "", outputText:
+		return outputText, nil
+	case outputJSON, outputYAML:
+		return format, nil
+	}
+
+	return "", fmt.Errorf("unknown output format '%s'", format)
+}
+
+// newResult returns a result of the command started right now
+func newResult(cmd *cobra.Command) *Result {
+	return &Result{
+		Command:    cmd.Name(),
+		StartedAt:  time.Now(),
+		Migrations: make([]MigrationResult, 0),
+	}
+}
+
+// writeResult completes the result with err, prints it in the format and returns err
+func writeResult(cmd *cobra.Command, format string, result *Result, err error) error {
+	result.Status = ResultOK
+	result.DurationMs = time.Since(result.StartedAt).Milliseconds()
+	if err != nil {
+		result.Status = ResultError
+		result.ExitCode = 1
+		result.Error = err.Error()
+	}
+
+	var data []byte
+	var encodeErr error
+	if format == outputYAML {
+		data, encodeErr = yaml.Marshal(result)
+	} else {
+		data, encodeErr = json.MarshalIndent(result, "", "  ")
+		data = append(data, '\n')
+	}
+
+	if encodeErr != nil {
+		return encodeErr
+	}
+
+	_, _ = cmd.OutOrStdout().Write(data)
+
+	return err
+}
+
+// namesResult returns migration results of the names
+func namesResult(names []string) []MigrationResult {
+	result := make([]MigrationResult, 0, len(names))
+	for _, name := range names {
+		result = append(result, MigrationResult{Name: name})
+	}
+
+	return result
+}
+
+// statesResult returns migration results of the names with the same state
+func statesResult(names []string, state string) []MigrationResult {
+	result := make([]MigrationResult, 0, len(names))
+	for _, name := range names {
+		result = append(result, MigrationResult{Name: name, State: state})
+	}
+
+	return result
+}
+
+// planResult returns migration results of the plan steps
+func planResult(plan mymigrate.MigrationPlan) []MigrationResult {
+	result := make([]MigrationResult, 0, len(plan.Steps))
+	for _, step := range plan.Steps {
+		result = append(result, MigrationResult{
+			Name:          step.Name,
+			Transactional: step.Transactional,
+			Irreversible:  step.Irreversible,
+			Statements:    step.Statements,
+		})
+	}
+
+	return result
+}
+
+// timeResult returns pointer to t or nil if t is zero
+func timeResult(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
@@ -0,0 +1,118 @@
+package cobracmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestOutputFormat(t *testing.T) {
+	testCases := map[string]struct {
+		flagPassed bool
+		value      string
+		expFormat  string
+		expErr     bool
+	}{
+		"flag isn't passed": {
+			expFormat: outputText,
+		},
+		"json": {
+			flagPassed: true,
+			value:      "json",
+			expFormat:  outputJSON,
+		},
+		"yaml": {
+			flagPassed: true,
+			value:      "yaml",
+			expFormat:  outputYAML,
+		},
+		"unknown format": {
+			flagPassed: true,
+			value:      "xml",
+			expErr:     true,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			if tc.flagPassed {
+				cmd.Flags().AddFlag(&pflag.Flag{
+					Name:  "output",
+					Value: StringValue{Value: tc.value},
+				})
+			}
+
+			format, err := outputFormat(cmd)
+			if tc.expErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expFormat, format)
+		})
+	}
+}
+
+func TestWriteResult(t *testing.T) {
+	testErr := errors.New("test error")
+
+	testCases := map[string]struct {
+		format    string
+		err       error
+		expStatus string
+		expCode   int
+	}{
+		"json": {
+			format:    outputJSON,
+			expStatus: ResultOK,
+		},
+		"yaml": {
+			format:    outputYAML,
+			expStatus: ResultOK,
+		},
+		"json with error": {
+			format:    outputJSON,
+			err:       testErr,
+			expStatus: ResultError,
+			expCode:   1,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			out := bytes.NewBufferString("")
+
+			cmd := &cobra.Command{Use: "apply"}
+			cmd.SetOut(out)
+
+			result := newResult(cmd)
+			result.Migrations = namesResult([]string{"mig_001"})
+
+			err := writeResult(cmd, tc.format, result, tc.err)
+			assert.Equal(t, tc.err, err)
+
+			var printed Result
+			if tc.format == outputYAML {
+				assert.NoError(t, yaml.Unmarshal(out.Bytes(), &printed))
+			} else {
+				assert.NoError(t, json.Unmarshal(out.Bytes(), &printed))
+			}
+
+			assert.Equal(t, "apply", printed.Command)
+			assert.Equal(t, tc.expStatus, printed.Status)
+			assert.Equal(t, tc.expCode, printed.ExitCode)
+			assert.EqualValues(t, []MigrationResult{{Name: "mig_001"}}, printed.Migrations)
+			if tc.err != nil {
+				assert.Equal(t, tc.err.Error(), printed.Error)
+			}
+		})
+	}
+}
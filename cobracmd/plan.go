@@ -0,0 +1,46 @@
+package cobracmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iamsalnikov/mymigrate"
+	"github.com/spf13/cobra"
+)
+
+// isDryRun tells whether the command should only print a plan
+func isDryRun(cmd *cobra.Command) bool {
+	return flagValue(cmd, "dry-run") == "true"
+}
+
+// printPlan prints migrations of the plan with statements of SQL migrations
+func printPlan(cmd *cobra.Command, plan mymigrate.MigrationPlan) {
+	out := cmd.OutOrStdout()
+
+	if plan.Direction == mymigrate.DirectionDown {
+		_, _ = fmt.Fprintln(out, "Migrations to down:")
+	} else {
+		_, _ = fmt.Fprintln(out, "Migrations to apply:")
+	}
+
+	for _, step := range plan.Steps {
+		notes := make([]string, 0, 2)
+		if step.Transactional {
+			notes = append(notes, "in transaction")
+		}
+
+		if step.Irreversible {
+			notes = append(notes, "irreversible")
+		}
+
+		if len(notes) > 0 {
+			_, _ = fmt.Fprintf(out, "%s (%s)\n", step.Name, strings.Join(notes, ", "))
+		} else {
+			_, _ = fmt.Fprintln(out, step.Name)
+		}
+
+		for _, statement := range step.Statements {
+			_, _ = fmt.Fprintf(out, "    %s\n", strings.ReplaceAll(statement, "\n", "\n    "))
+		}
+	}
+}
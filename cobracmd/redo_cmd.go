@@ -0,0 +1,74 @@
+package cobracmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/iamsalnikov/mymigrate"
+	"github.com/spf13/cobra"
+)
+
+// RedoCmd is a cobra command that reverts the latest applied migrations and applies them again
+var RedoCmd = &cobra.Command{
+	Use:   "redo [number]",
+	Short: "revert number of the latest applied migrations and apply them again (1 by default)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  RedoRunE,
+}
+
+// RedoRunE is a cobra run function for RedoCmd command
+func RedoRunE(cmd *cobra.Command, args []string) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	setupLogger(cmd)
+
+	number := 1
+	if len(args) == 1 {
+		number, err = strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	result := newResult(cmd)
+	reverted, applied, err := mymigrate.RedoContext(commandContext(cmd), number)
+	if format != outputText {
+		result.Migrations = redoResult(reverted, applied)
+		return writeResult(cmd, format, result, err)
+	}
+
+	for _, name := range reverted {
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Reverted %s\n", name)
+	}
+
+	for _, name := range applied {
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Applied %s\n", name)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(reverted) == 0 {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "There is nothing to redo")
+	}
+
+	return nil
+}
+
+// redoResult returns migration results of reverted and then applied migrations with their directions
+func redoResult(reverted, applied []string) []MigrationResult {
+	result := make([]MigrationResult, 0, len(reverted)+len(applied))
+	for _, name := range reverted {
+		result = append(result, MigrationResult{Name: name, Direction: string(mymigrate.DirectionDown)})
+	}
+
+	for _, name := range applied {
+		result = append(result, MigrationResult{Name: name, Direction: string(mymigrate.DirectionUp)})
+	}
+
+	return result
+}
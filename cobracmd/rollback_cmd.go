@@ -0,0 +1,88 @@
+package cobracmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/iamsalnikov/mymigrate"
+	"github.com/spf13/cobra"
+)
+
+// RollbackCmd is a cobra command that reverts migrations of the latest apply runs
+var RollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "revert migrations of the latest batch, i.e. migrations applied by the latest apply",
+	Args:  cobra.NoArgs,
+	RunE:  RollbackRunE,
+}
+
+func init() {
+	RollbackCmd.Flags().Int("batches", 1, "number of the latest batches to revert")
+	RollbackCmd.Flags().Bool("dry-run", false, "print migrations that would be reverted without reverting them")
+}
+
+// RollbackRunE is a cobra run function for RollbackCmd command
+func RollbackRunE(cmd *cobra.Command, args []string) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	setupLogger(cmd)
+
+	number, err := strconv.Atoi(flagValue(cmd, "batches"))
+	if err != nil {
+		return err
+	}
+
+	result := newResult(cmd)
+	if isDryRun(cmd) {
+		return rollbackDryRun(cmd, format, result, number)
+	}
+
+	list, err := mymigrate.RollbackBatchesContext(commandContext(cmd), number)
+	if format != outputText {
+		result.Migrations = namesResult(list)
+		return writeResult(cmd, format, result, err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(list) == 0 {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "There is nothing to roll back")
+
+		return nil
+	}
+
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), "List of reverted migrations:")
+	for _, mig := range list {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), mig)
+	}
+
+	return nil
+}
+
+func rollbackDryRun(cmd *cobra.Command, format string, result *Result, number int) error {
+	plan, err := mymigrate.RollbackPlanContext(commandContext(cmd), number)
+	if format != outputText {
+		result.DryRun = true
+		result.Migrations = planResult(plan)
+		return writeResult(cmd, format, result, err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(plan.Steps) == 0 {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "There is nothing to roll back")
+
+		return nil
+	}
+
+	printPlan(cmd, plan)
+
+	return nil
+}
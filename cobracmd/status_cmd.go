@@ -0,0 +1,89 @@
+package cobracmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/iamsalnikov/mymigrate"
+	"github.com/spf13/cobra"
+)
+
+// StatusCmd is a cobra command that prints states of registered and applied migrations
+var StatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "shows applied, pending, not registered and out of order migrations",
+	RunE:  StatusRunE,
+}
+
+func init() {
+	StatusCmd.Flags().String("format", "table", "output format: table or json")
+	_ = StatusCmd.Flags().MarkDeprecated("format", "use --output instead")
+}
+
+// StatusRunE is a cobra run function for StatusCmd command
+func StatusRunE(cmd *cobra.Command, args []string) error {
+	format, err := statusFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	result := newResult(cmd)
+	statuses, err := mymigrate.StatusContext(commandContext(cmd))
+	if format != outputText {
+		for _, status := range statuses {
+			result.Migrations = append(result.Migrations, MigrationResult{
+				Name:         status.Name,
+				State:        string(status.State),
+				AppliedAt:    timeResult(status.AppliedAt),
+				Irreversible: status.Irreversible,
+			})
+		}
+
+		return writeResult(cmd, format, result, err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(statuses) == 0 {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "There are no migrations")
+
+		return nil
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tSTATE\tAPPLIED AT\tIRREVERSIBLE")
+	for _, status := range statuses {
+		appliedAt := ""
+		if !status.AppliedAt.IsZero() {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+
+		irreversible := ""
+		if status.Irreversible {
+			irreversible = "yes"
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status.Name, status.State, appliedAt, irreversible)
+	}
+
+	return w.Flush()
+}
+
+// statusFormat returns output format of the command taking deprecated --format flag into account
+func statusFormat(cmd *cobra.Command) (string, error) {
+	output := cmd.Flag("output")
+	if output != nil && output.Changed {
+		return outputFormat(cmd)
+	}
+
+	switch format := flagValue(cmd, "format"); format {
+	case "", "table":
+		return outputFormat(cmd)
+	case outputJSON:
+		return outputJSON, nil
+	default:
+		return "", fmt.Errorf("unknown format '%s'", format)
+	}
+}
@@ -0,0 +1,66 @@
+package cobracmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusFormat(t *testing.T) {
+	testCases := map[string]struct {
+		output string
+		format string
+
+		expFormat string
+		expErr    string
+	}{
+		"no flags": {
+			expFormat: outputText,
+		},
+		"deprecated table format": {
+			format:    "table",
+			expFormat: outputText,
+		},
+		"deprecated json format": {
+			format:    "json",
+			expFormat: outputJSON,
+		},
+		"deprecated unknown format": {
+			format: "xml",
+			expErr: "unknown format 'xml'",
+		},
+		"output flag": {
+			output:    "yaml",
+			expFormat: outputYAML,
+		},
+		"output flag wins over deprecated format": {
+			output:    "yaml",
+			format:    "json",
+			expFormat: outputYAML,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			cmd.Flags().String("output", outputText, "")
+			cmd.Flags().String("format", "table", "")
+			if len(tc.output) > 0 {
+				assert.NoError(t, cmd.Flags().Set("output", tc.output))
+			}
+			if len(tc.format) > 0 {
+				assert.NoError(t, cmd.Flags().Set("format", tc.format))
+			}
+
+			format, err := statusFormat(cmd)
+			if len(tc.expErr) > 0 {
+				assert.EqualError(t, err, tc.expErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expFormat, format)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package cobracmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/iamsalnikov/mymigrate"
+	"github.com/spf13/cobra"
+)
+
+// VerifyCmd is a cobra command that checks applied migrations against registered ones
+var VerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "reports applied migrations that were changed or aren't registered anymore",
+	RunE:  VerifyRunE,
+}
+
+// VerifyRunE is a cobra run function for VerifyCmd command
+func VerifyRunE(cmd *cobra.Command, args []string) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	result := newResult(cmd)
+	drifts, err := mymigrate.VerifyContext(commandContext(cmd))
+	if err == nil && len(drifts) > 0 {
+		err = errors.New("applied migrations differ from registered ones")
+	}
+
+	if format != outputText {
+		for _, drift := range drifts {
+			result.Migrations = append(result.Migrations, MigrationResult{
+				Name:             drift.Name,
+				State:            string(drift.Kind),
+				RecordedChecksum: drift.RecordedChecksum,
+				Checksum:         drift.Checksum,
+			})
+		}
+
+		return writeResult(cmd, format, result, err)
+	}
+
+	if len(drifts) == 0 {
+		if err != nil {
+			return err
+		}
+
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "All applied migrations match registered ones")
+
+		return nil
+	}
+
+	for _, drift := range drifts {
+		switch drift.Kind {
+		case mymigrate.DriftChecksumMismatch:
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s: %s (recorded %s, registered %s)\n", drift.Name, drift.Kind, drift.RecordedChecksum, drift.Checksum)
+		default:
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", drift.Name, drift.Kind)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,183 @@
+package mymigrate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrDependency is returned by Down and DownTo if a migration can't be reverted because applied migrations depend on it
+var ErrDependency = errors.New("invalid migration dependencies")
+
+// DependsOn declares migrations that have to be applied before the migration.
+// New migrations are applied in the order of names unless dependencies require another order,
+// so independent migrations of parallel branches don't conflict
+func DependsOn(names ...string) MigrationOption {
+	return func(m *mig) {
+		m.dependsOn = append(m.dependsOn, names...)
+	}
+}
+
+// Dependencies returns names of registered migrations with names of migrations they depend on
+func (m *Migrator) Dependencies() map[string][]string {
+	result := make(map[string][]string, len(m.migrations))
+	for name, mig := range m.migrations {
+		result[name] = append([]string{}, mig.dependsOn...)
+	}
+
+	return result
+}
+
+// sortedNames returns names of registered migrations sorted by name
+func (m *Migrator) sortedNames() []string {
+	names := make([]string, 0, len(m.migrations))
+	for name := range m.migrations {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// dependencyProblems returns descriptions of dependencies that aren't registered and of a dependency cycle
+func (m *Migrator) dependencyProblems() []string {
+	problems := make([]string, 0)
+	for _, name := range m.sortedNames() {
+		for _, dep := range m.migrations[name].dependsOn {
+			if _, ok := m.migrations[dep]; !ok {
+				problems = append(problems, fmt.Sprintf("migration '%s' depends on unregistered migration '%s'", name, dep))
+			}
+		}
+	}
+
+	if cycle := m.dependencyCycle(); len(cycle) > 0 {
+		problems = append(problems, fmt.Sprintf("dependency cycle %s", strings.Join(cycle, " -> ")))
+	}
+
+	return problems
+}
+
+// dependencyCycle returns names of the first found cycle of dependencies starting and ending with the same name
+func (m *Migrator) dependencyCycle() []string {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	marks := make(map[string]int, len(m.migrations))
+	path := make([]string, 0)
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch marks[name] {
+		case visited:
+			return nil
+		case visiting:
+			for i, n := range path {
+				if n == name {
+					return append(append([]string{}, path[i:]...), name)
+				}
+			}
+		}
+
+		marks[name] = visiting
+		path = append(path, name)
+		for _, dep := range m.migrations[name].dependsOn {
+			if _, ok := m.migrations[dep]; !ok {
+				continue
+			}
+
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+
+		path = path[:len(path)-1]
+		marks[name] = visited
+
+		return nil
+	}
+
+	for _, name := range m.sortedNames() {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
+
+// sortByDependencies orders names so that every migration follows its dependencies among names.
+// Migrations that are free to go are taken in the order of names.
+// Names of a dependency cycle are put to the end in the order of names
+func (m *Migrator) sortByDependencies(names []string) []string {
+	pending := make(map[string]bool, len(names))
+	for _, name := range names {
+		pending[name] = true
+	}
+
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+
+	result := make([]string, 0, len(names))
+	for len(result) < len(names) {
+		next := ""
+		for _, name := range sorted {
+			if pending[name] && !m.waitsForPending(name, pending) {
+				next = name
+				break
+			}
+		}
+
+		if len(next) == 0 {
+			for _, name := range sorted {
+				if pending[name] {
+					result = append(result, name)
+				}
+			}
+
+			break
+		}
+
+		pending[next] = false
+		result = append(result, next)
+	}
+
+	return result
+}
+
+// waitsForPending tells whether the migration depends on a pending migration
+func (m *Migrator) waitsForPending(name string, pending map[string]bool) bool {
+	for _, dep := range m.migrations[name].dependsOn {
+		if pending[dep] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkDependents returns an error if applied migrations that aren't going to be reverted with names depend on them
+func (m *Migrator) checkDependents(records []HistoryRecord, names []string) error {
+	reverted := make(map[string]bool, len(names))
+	for _, name := range names {
+		reverted[name] = true
+	}
+
+	for _, record := range records {
+		if reverted[record.Name] {
+			continue
+		}
+
+		for _, dep := range m.migrations[record.Name].dependsOn {
+			if reverted[dep] {
+				return fmt.Errorf("%w: can't revert migration '%s' while applied migration '%s' depends on it",
+					ErrDependency, dep, record.Name)
+			}
+		}
+	}
+
+	return nil
+}
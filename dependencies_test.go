@@ -0,0 +1,164 @@
+package mymigrate
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iamsalnikov/mymigrate/migrationtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrator_NewNamesWithDependencies(t *testing.T) {
+	testCases := map[string]struct {
+		dependencies map[string][]string
+		applied      []string
+
+		expectNames []string
+	}{
+		"no dependencies": {
+			expectNames: []string{"a_mig", "b_mig", "c_mig", "d_mig"},
+		},
+		"dependency on a later name": {
+			dependencies: map[string][]string{
+				"a_mig": {"c_mig"},
+			},
+			expectNames: []string{"b_mig", "c_mig", "a_mig", "d_mig"},
+		},
+		"chain of dependencies": {
+			dependencies: map[string][]string{
+				"a_mig": {"b_mig"},
+				"b_mig": {"d_mig"},
+			},
+			expectNames: []string{"c_mig", "d_mig", "b_mig", "a_mig"},
+		},
+		"applied dependency": {
+			dependencies: map[string][]string{
+				"a_mig": {"c_mig"},
+			},
+			applied:     []string{"c_mig"},
+			expectNames: []string{"a_mig", "b_mig", "d_mig"},
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			provider := migrationtest.NewMockDbProvider(ctrl)
+			provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+			provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords(tc.applied...), nil)
+
+			m := NewMigrator(provider)
+			for _, name := range []string{"a_mig", "b_mig", "c_mig", "d_mig"} {
+				m.Add(name, func(db *sql.DB) error { return nil }, nil, DependsOn(tc.dependencies[name]...))
+			}
+
+			names, err := m.NewNames()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectNames, names)
+		})
+	}
+}
+
+func TestMigrator_ApplyWithInvalidDependencies(t *testing.T) {
+	testCases := map[string]struct {
+		dependencies map[string][]string
+
+		expectErr string
+	}{
+		"missing dependency": {
+			dependencies: map[string][]string{
+				"b_mig": {"x_mig"},
+			},
+			expectErr: "invalid migrations: migration 'b_mig' depends on unregistered migration 'x_mig'",
+		},
+		"cycle": {
+			dependencies: map[string][]string{
+				"a_mig": {"b_mig"},
+				"b_mig": {"c_mig"},
+				"c_mig": {"a_mig"},
+			},
+			expectErr: "invalid migrations: dependency cycle a_mig -> b_mig -> c_mig -> a_mig",
+		},
+		"self dependency": {
+			dependencies: map[string][]string{
+				"c_mig": {"c_mig"},
+			},
+			expectErr: "invalid migrations: dependency cycle c_mig -> c_mig",
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			// the database isn't touched
+			provider := migrationtest.NewMockDbProvider(ctrl)
+
+			m := NewMigrator(provider)
+			for _, name := range []string{"a_mig", "b_mig", "c_mig"} {
+				m.Add(name, func(db *sql.DB) error { return nil }, nil, DependsOn(tc.dependencies[name]...))
+			}
+
+			applied, err := m.Apply()
+			assert.EqualError(t, err, tc.expectErr)
+			assert.Nil(t, applied)
+		})
+	}
+}
+
+func TestMigrator_DownWithDependents(t *testing.T) {
+	testCases := map[string]struct {
+		down func(m *Migrator) ([]string, error)
+
+		expectReverted []string
+		expectErr      string
+	}{
+		"dependent is reverted too": {
+			down: func(m *Migrator) ([]string, error) {
+				return m.Down(0)
+			},
+			expectReverted: []string{"c_mig", "b_mig", "a_mig"},
+		},
+		"dependent remains applied": {
+			down: func(m *Migrator) ([]string, error) {
+				return m.Down(2)
+			},
+			expectErr: "invalid migration dependencies: can't revert migration 'b_mig' while applied migration 'a_mig' depends on it",
+		},
+		"dependent remains applied after down to": {
+			down: func(m *Migrator) ([]string, error) {
+				return m.DownTo("a_mig")
+			},
+			expectErr: "invalid migration dependencies: can't revert migration 'b_mig' while applied migration 'a_mig' depends on it",
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			provider := migrationtest.NewMockDbProvider(ctrl)
+			provider.EXPECT().GetDb().AnyTimes()
+			provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+			// b_mig was marked as applied after a_mig that depends on it
+			provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords("c_mig", "b_mig", "a_mig"), nil)
+			provider.EXPECT().MarkApplied(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			provider.EXPECT().DeleteApplied(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			noop := func(db *sql.DB) error { return nil }
+			m := NewMigrator(provider)
+			m.Add("a_mig", noop, noop, DependsOn("b_mig"))
+			m.Add("b_mig", noop, noop)
+			m.Add("c_mig", noop, noop, DependsOn("b_mig"))
+
+			reverted, err := tc.down(m)
+			if len(tc.expectErr) > 0 {
+				assert.EqualError(t, err, tc.expectErr)
+				assert.Nil(t, reverted)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectReverted, reverted)
+		})
+	}
+}
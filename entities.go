@@ -3,7 +3,8 @@ package mymigrate
 import (
 	"context"
 	"database/sql"
-	"time"
+
+	"github.com/iamsalnikov/mymigrate/provider"
 )
 
 // UpFunc is a function that ups migration
@@ -12,17 +13,108 @@ type UpFunc func(db *sql.DB) error
 // DownFunc is a function that downs migration
 type DownFunc func(db *sql.DB) error
 
+// TxUpFunc is a function that ups migration inside a transaction
+type TxUpFunc func(tx *sql.Tx) error
+
+// TxDownFunc is a function that downs migration inside a transaction
+type TxDownFunc func(tx *sql.Tx) error
+
+// UpContextFunc is a function that ups migration and respects ctx cancellation
+type UpContextFunc func(ctx context.Context, db *sql.DB) error
+
+// DownContextFunc is a function that downs migration and respects ctx cancellation
+type DownContextFunc func(ctx context.Context, db *sql.DB) error
+
+// TxUpContextFunc is a function that ups migration inside a transaction and respects ctx cancellation
+type TxUpContextFunc func(ctx context.Context, tx *sql.Tx) error
+
+// TxDownContextFunc is a function that downs migration inside a transaction and respects ctx cancellation
+type TxDownContextFunc func(ctx context.Context, tx *sql.Tx) error
+
 type mig struct {
-	name string
-	up   UpFunc
-	down DownFunc
+	name   string
+	up     UpContextFunc
+	down   DownContextFunc
+	upTx   TxUpContextFunc
+	downTx TxDownContextFunc
+	// SQL of SQL migration, it is split into statements according to the database provider
+	upSQL   string
+	downSQL string
+	// checksum recorded to the history when migration is applied
+	checksum string
+	// names of migrations that have to be applied before the migration
+	dependsOn []string
+	// migration can't be reverted even if it has down function
+	irreversible bool
+}
+
+// MigrationOption configures a migration when it is added
+type MigrationOption func(m *mig)
+
+// WithVersion sets version of a golang migration. It is recorded to the history as the migration checksum,
+// so bump it when an applied migration is changed and Verify will report the drift
+func WithVersion(version string) MigrationOption {
+	return func(m *mig) {
+		m.checksum = version
+	}
+}
+
+// Irreversible marks a migration that can't be reverted, e.g. a data migration that loses information.
+// A golang migration added without down function is irreversible as well
+func Irreversible() MigrationOption {
+	return func(m *mig) {
+		m.irreversible = true
+	}
+}
+
+// HistoryRecord is a row of the migration history
+type HistoryRecord = provider.HistoryRecord
+
+// transactional tells whether migration should be run inside a transaction
+func (m mig) transactional() bool {
+	return m.upTx != nil
+}
+
+// reversible tells whether migration can be reverted
+func (m mig) reversible() bool {
+	return !m.irreversible && (m.down != nil || m.downTx != nil)
 }
 
 // DbProvider - interface for interacting with the database
 type DbProvider interface {
 	GetDb() *sql.DB
-	CreateMigrationsTable() error
-	GetApplied(context.Context) ([]string, error)
-	MarkApplied(context.Context, string, time.Time) error
+	CreateMigrationsTable(context.Context) error
+	// GetApplied returns records from the latest applied to the earliest one
+	GetApplied(context.Context) ([]provider.HistoryRecord, error)
+	// MarkApplied inserts the record or overwrites the existing record of the same migration
+	MarkApplied(context.Context, provider.HistoryRecord) error
 	DeleteApplied(context.Context, string) error
 }
+
+// TxProvider - interface for providers that can write migration history inside a transaction.
+// Transactional migrations can be applied only with such providers
+type TxProvider interface {
+	DbProvider
+	MarkAppliedTx(context.Context, *sql.Tx, provider.HistoryRecord) error
+	DeleteAppliedTx(context.Context, *sql.Tx, string) error
+}
+
+// DDLCommitter - interface for providers of databases that commit DDL statements implicitly, e.g. MySQL.
+// A failed transactional migration can't be rolled back completely there,
+// so it is marked as dirty before it runs like a non-transactional one
+type DDLCommitter interface {
+	CommitsDDL() bool
+}
+
+// BackslashEscaper - interface for providers of databases that treat a backslash inside strings
+// as an escape character, e.g. MySQL. Statements of SQL migrations are split according to it
+type BackslashEscaper interface {
+	BackslashEscapes() bool
+}
+
+// Locker - interface for providers that can hold a cross-process migration lock.
+// Apply and Down hold the lock for the whole run, so concurrent deploys don't apply the same migrations twice
+type Locker interface {
+	Lock(context.Context) error
+	Unlock(context.Context) error
+}
@@ -0,0 +1,94 @@
+package mymigrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// ForceApplied marks the migration as applied without running it.
+// Use it to resolve a dirty migration that was finished by hand
+func (m *Migrator) ForceApplied(name string) error {
+	return m.ForceAppliedContext(context.Background(), name)
+}
+
+// ForceAppliedContext marks the migration as applied without running it.
+// A migration that has a history row, e.g. a dirty one, stays in the batch it was applied by
+func (m *Migrator) ForceAppliedContext(ctx context.Context, name string) error {
+	if _, ok := m.migrations[name]; !ok {
+		return fmt.Errorf("can't find migration '%s'", name)
+	}
+
+	return m.withLock(ctx, func() error {
+		records, err := m.getApplied(ctx, m.provider)
+		if err != nil {
+			return err
+		}
+
+		record := m.historyRecord(name, DirectionUp)
+		for _, applied := range records {
+			if applied.Name == name {
+				record.Batch = applied.Batch
+			}
+		}
+
+		return m.markApplied(ctx, m.provider, record)
+	})
+}
+
+// ForceReverted deletes the migration from the history without running it.
+// Use it to resolve a dirty migration that was reverted by hand
+func (m *Migrator) ForceReverted(name string) error {
+	return m.ForceRevertedContext(context.Background(), name)
+}
+
+// ForceRevertedContext deletes the migration from the history without running it
+func (m *Migrator) ForceRevertedContext(ctx context.Context, name string) error {
+	return m.withLock(ctx, func() error {
+		err := m.createMigrationsTable(ctx, m.provider)
+		if err != nil {
+			return err
+		}
+
+		return m.providerCall(ctx, "DeleteApplied", func(ctx context.Context) error {
+			return m.provider.DeleteApplied(ctx, name)
+		})
+	})
+}
+
+// Baseline marks registered migrations up to and including the named one as applied without running them.
+// Use it to adopt a database whose schema was created by hand or by another tool
+func (m *Migrator) Baseline(upTo string) ([]string, error) {
+	return m.BaselineContext(context.Background(), upTo)
+}
+
+// BaselineContext marks registered migrations up to and including the named one as applied without running them.
+// It returns names of marked migrations. Migrations that are already applied are left as is
+func (m *Migrator) BaselineContext(ctx context.Context, upTo string) ([]string, error) {
+	err := m.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var marked []string
+	err = m.withLock(ctx, func() error {
+		names, _, err := m.namesToApplyTo(ctx, upTo)
+		if err != nil {
+			return err
+		}
+
+		marked = make([]string, 0, len(names))
+		for _, name := range names {
+			err = m.markApplied(ctx, m.provider, m.historyRecord(name, DirectionUp))
+			if err != nil {
+				return err
+			}
+
+			m.logger.Info("migration is marked as applied", "migration", name)
+			marked = append(marked, name)
+		}
+
+		return nil
+	})
+
+	return marked, err
+}
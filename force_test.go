@@ -0,0 +1,92 @@
+package mymigrate
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iamsalnikov/mymigrate/migrationtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrator_ForceApplied(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	provider := migrationtest.NewMockDbProvider(ctrl)
+	provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords(), nil)
+	provider.EXPECT().MarkApplied(gomock.Any(), appliedRecord("mig_001")).Return(nil)
+
+	m := NewMigrator(provider)
+	m.Add("mig_001", nil, nil)
+
+	assert.NoError(t, m.ForceApplied("mig_001"))
+	assert.EqualError(t, m.ForceApplied("mig_404"), "can't find migration 'mig_404'")
+}
+
+func TestMigrator_ForceReverted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	provider := migrationtest.NewMockDbProvider(ctrl)
+	provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	provider.EXPECT().DeleteApplied(gomock.Any(), "mig_001").Return(nil)
+
+	m := NewMigrator(provider)
+
+	assert.NoError(t, m.ForceReverted("mig_001"))
+}
+
+func TestMigrator_Baseline(t *testing.T) {
+	testCases := map[string]struct {
+		applied []string
+		upTo    string
+
+		expectMarked []string
+		expectErr    string
+	}{
+		"empty history": {
+			applied:      []string{},
+			upTo:         "mig_002",
+			expectMarked: []string{"mig_001", "mig_002"},
+		},
+		"partially applied": {
+			applied:      []string{"mig_001"},
+			upTo:         "mig_003",
+			expectMarked: []string{"mig_002", "mig_003"},
+		},
+		"already applied": {
+			applied:      []string{"mig_002", "mig_001"},
+			upTo:         "mig_002",
+			expectMarked: []string{},
+		},
+		"unknown migration": {
+			applied:   []string{},
+			upTo:      "mig_404",
+			expectErr: "can't find migration 'mig_404'",
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			provider := migrationtest.NewMockDbProvider(ctrl)
+			provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+			provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords(tc.applied...), nil).AnyTimes()
+			for _, name := range tc.expectMarked {
+				provider.EXPECT().MarkApplied(gomock.Any(), appliedRecord(name)).Return(nil)
+			}
+
+			m := NewMigrator(provider)
+			for _, name := range []string{"mig_001", "mig_002", "mig_003"} {
+				m.Add(name, func(db *sql.DB) error { return nil }, nil)
+			}
+
+			marked, err := m.Baseline(tc.upTo)
+			if len(tc.expectErr) > 0 {
+				assert.EqualError(t, err, tc.expectErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectMarked, marked)
+		})
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/iamsalnikov/mymigrate
 
-go 1.14
+go 1.16
 
 require (
 	github.com/DATA-DOG/go-sqlmock v1.5.0
@@ -8,4 +8,5 @@ require (
 	github.com/spf13/cobra v1.1.0
 	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.4.0
+	gopkg.in/yaml.v2 v2.3.0
 )
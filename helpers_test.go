@@ -1,17 +1,58 @@
 package mymigrate
 
+import "fmt"
+
 func resetMigrations() {
-	migrations = make(map[string]mig)
+	defaultMigrator.migrations = make(map[string]mig)
+	defaultMigrator.problems = nil
 }
 
 func resetAppliedFunc() {
-	getApplied = defaultAppliedFunc
+	defaultMigrator.getApplied = defaultMigrator.defaultApplied
 }
 
 func resetMarkAppliedFunc() {
-	markApplied = defaultMarkAppliedFunc
+	defaultMigrator.markApplied = defaultMigrator.defaultMarkApplied
+}
+
+func resetMarkDirtyFunc() {
+	defaultMigrator.markDirty = defaultMigrator.defaultMarkDirty
 }
 
 func resetDownFunc() {
-	down = defaultDownFunc
+	defaultMigrator.down = defaultMigrator.defaultDown
+}
+
+func historyRecords(names ...string) []HistoryRecord {
+	records := make([]HistoryRecord, 0, len(names))
+	for _, name := range names {
+		records = append(records, HistoryRecord{Name: name})
+	}
+
+	return records
+}
+
+// recordMatcher matches a history record by the migration name and the dirty flag
+type recordMatcher struct {
+	name  string
+	dirty bool
+}
+
+// appliedRecord returns a matcher of the history record of applied migration
+func appliedRecord(name string) recordMatcher {
+	return recordMatcher{name: name}
+}
+
+// dirtyRecord returns a matcher of the history record of started migration
+func dirtyRecord(name string) recordMatcher {
+	return recordMatcher{name: name, dirty: true}
+}
+
+func (m recordMatcher) Matches(x interface{}) bool {
+	record, ok := x.(HistoryRecord)
+	return ok && record.Name == m.name && record.Dirty == m.dirty
+}
+
+func (m recordMatcher) String() string {
+	return fmt.Sprintf("is history record of '%s' with dirty=%t", m.name, m.dirty)
 }
@@ -0,0 +1,160 @@
+package mymigrate
+
+import (
+	"context"
+	"time"
+)
+
+// Event describes a migration run or a single migration of the run for hooks
+type Event struct {
+	// Name of the migration. It is empty for BeforeRun and AfterRun
+	Name      string
+	Direction Direction
+	// Migrations are names of migrations to be run for BeforeRun and names of migrations that were run for AfterRun
+	Migrations []string
+	// Duration of the migration or of the whole run. It is zero for before-hooks
+	Duration time.Duration
+	// Err is an error the migration or the run failed with
+	Err error
+}
+
+// Hooks are callbacks around migration runs. Any of them can be nil.
+// An error returned by a before-hook vetoes the operation and is returned by the run
+type Hooks struct {
+	// BeforeRun is called before migrations of Apply or Down are run
+	BeforeRun func(ctx context.Context, event Event) error
+	// BeforeMigration is called before every migration
+	BeforeMigration func(ctx context.Context, event Event) error
+	// AfterMigration is called after every migration, whether it succeeded or not
+	AfterMigration func(ctx context.Context, event Event)
+	// OnError is called when a migration fails
+	OnError func(ctx context.Context, event Event)
+	// AfterRun is called after the run even if it failed
+	AfterRun func(ctx context.Context, event Event)
+}
+
+// AddHooks adds hooks to the migrator. Hooks are called in the order they were added
+func (m *Migrator) AddHooks(hooks Hooks) {
+	m.hooks = append(m.hooks, hooks)
+}
+
+// namesFunc returns names of migrations to run and the history records they were chosen from
+type namesFunc func() ([]string, []HistoryRecord, error)
+
+// runMigrations applies or downs migrations returned by names holding the migration lock
+// if registered migrations are valid
+func (m *Migrator) runMigrations(ctx context.Context, direction Direction, names namesFunc) ([]string, error) {
+	err := m.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []string
+	err = m.withLock(ctx, func() error {
+		var err error
+		done, err = m.runLocked(ctx, direction, names)
+		return err
+	})
+
+	return done, err
+}
+
+// runLocked applies or downs migrations returned by names. The caller should hold the migration lock.
+// BeforeRun and AfterRun hooks are called around the run
+func (m *Migrator) runLocked(ctx context.Context, direction Direction, names namesFunc) ([]string, error) {
+	started := time.Now()
+	m.logger.Info("migration run started", "direction", direction)
+
+	var done []string
+	err := func() error {
+		toRun, records, err := names()
+		if err != nil {
+			return err
+		}
+
+		m.logger.Info("migrations to run", "direction", direction, "migrations", toRun)
+		err = m.beforeRun(ctx, Event{Direction: direction, Migrations: toRun})
+		if err != nil {
+			return err
+		}
+
+		if direction == DirectionUp {
+			done, err = m.applyNames(ctx, toRun, nextBatch(records))
+		} else {
+			done, err = m.downNames(ctx, toRun, records)
+		}
+
+		m.updatePending(ctx)
+
+		return err
+	}()
+
+	duration := time.Since(started)
+	if err != nil {
+		m.logger.Error("migration run failed", "direction", direction, "migrations", done, "duration", duration, "error", err)
+	} else {
+		m.logger.Info("migration run finished", "direction", direction, "migrations", done, "duration", duration)
+	}
+
+	for _, hooks := range m.hooks {
+		if hooks.AfterRun != nil {
+			hooks.AfterRun(ctx, Event{Direction: direction, Migrations: done, Duration: duration, Err: err})
+		}
+	}
+
+	return done, err
+}
+
+// migrate runs f of the named migration between BeforeMigration and AfterMigration hooks
+func (m *Migrator) migrate(ctx context.Context, name string, direction Direction, f func() error) error {
+	event := Event{Name: name, Direction: direction}
+	for _, hooks := range m.hooks {
+		if hooks.BeforeMigration == nil {
+			continue
+		}
+
+		err := hooks.BeforeMigration(ctx, event)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.logger.Info("migration started", "migration", name, "direction", direction)
+	started := time.Now()
+	event.Err = f()
+	event.Duration = time.Since(started)
+	m.observeMigration(name, direction, event.Duration, event.Err)
+
+	if event.Err != nil {
+		m.logger.Error("migration failed", "migration", name, "direction", direction, "duration", event.Duration, "error", event.Err)
+	} else {
+		m.logger.Info("migration finished", "migration", name, "direction", direction, "duration", event.Duration)
+	}
+
+	for _, hooks := range m.hooks {
+		if event.Err != nil && hooks.OnError != nil {
+			hooks.OnError(ctx, event)
+		}
+
+		if hooks.AfterMigration != nil {
+			hooks.AfterMigration(ctx, event)
+		}
+	}
+
+	return event.Err
+}
+
+func (m *Migrator) beforeRun(ctx context.Context, event Event) error {
+	for _, hooks := range m.hooks {
+		if hooks.BeforeRun == nil {
+			continue
+		}
+
+		err := hooks.BeforeRun(ctx, event)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
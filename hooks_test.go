@@ -0,0 +1,161 @@
+package mymigrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iamsalnikov/mymigrate/migrationtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingHooks returns hooks that append called events to calls
+func recordingHooks(calls *[]string) Hooks {
+	errText := func(err error) string {
+		if err == nil {
+			return "<nil>"
+		}
+
+		return err.Error()
+	}
+
+	return Hooks{
+		BeforeRun: func(ctx context.Context, event Event) error {
+			*calls = append(*calls, fmt.Sprintf("before run %s %v", event.Direction, event.Migrations))
+			return nil
+		},
+		BeforeMigration: func(ctx context.Context, event Event) error {
+			*calls = append(*calls, fmt.Sprintf("before %s %s", event.Direction, event.Name))
+			return nil
+		},
+		AfterMigration: func(ctx context.Context, event Event) {
+			*calls = append(*calls, fmt.Sprintf("after %s %s %s", event.Direction, event.Name, errText(event.Err)))
+		},
+		OnError: func(ctx context.Context, event Event) {
+			*calls = append(*calls, fmt.Sprintf("error %s %s %s", event.Direction, event.Name, errText(event.Err)))
+		},
+		AfterRun: func(ctx context.Context, event Event) {
+			*calls = append(*calls, fmt.Sprintf("after run %s %v %s", event.Direction, event.Migrations, errText(event.Err)))
+		},
+	}
+}
+
+func TestMigrator_Hooks(t *testing.T) {
+	upErr := errors.New("up error")
+	vetoErr := errors.New("maintenance window is closed")
+
+	testCases := map[string]struct {
+		applied []string
+		upErr   error
+		hooks   Hooks
+		run     func(m *Migrator) ([]string, error)
+
+		expectCalls []string
+		expectDone  []string
+		expectErr   error
+	}{
+		"apply": {
+			applied: []string{},
+			run:     (*Migrator).Apply,
+			expectCalls: []string{
+				"before run up [mig_001 mig_002]",
+				"before up mig_001",
+				"after up mig_001 <nil>",
+				"before up mig_002",
+				"after up mig_002 <nil>",
+				"after run up [mig_001 mig_002] <nil>",
+			},
+			expectDone: []string{"mig_001", "mig_002"},
+		},
+		"failed migration": {
+			applied: []string{},
+			upErr:   upErr,
+			run:     (*Migrator).Apply,
+			expectCalls: []string{
+				"before run up [mig_001 mig_002]",
+				"before up mig_001",
+				"error up mig_001 up error",
+				"after up mig_001 up error",
+				"after run up [] up error",
+			},
+			expectDone: []string{},
+			expectErr:  upErr,
+		},
+		"down": {
+			applied: []string{"mig_002", "mig_001"},
+			run: func(m *Migrator) ([]string, error) {
+				return m.Down(1)
+			},
+			expectCalls: []string{
+				"before run down [mig_002]",
+				"before down mig_002",
+				"after down mig_002 <nil>",
+				"after run down [mig_002] <nil>",
+			},
+			expectDone: []string{"mig_002"},
+		},
+		"run is vetoed": {
+			applied: []string{},
+			hooks: Hooks{
+				BeforeRun: func(ctx context.Context, event Event) error {
+					return vetoErr
+				},
+			},
+			run: (*Migrator).Apply,
+			expectCalls: []string{
+				"before run up [mig_001 mig_002]",
+				"after run up [] maintenance window is closed",
+			},
+			expectErr: vetoErr,
+		},
+		"migration is vetoed": {
+			applied: []string{"mig_001"},
+			hooks: Hooks{
+				BeforeMigration: func(ctx context.Context, event Event) error {
+					return vetoErr
+				},
+			},
+			run: (*Migrator).Apply,
+			expectCalls: []string{
+				"before run up [mig_002]",
+				"before up mig_002",
+				"after run up [] maintenance window is closed",
+			},
+			expectDone: []string{},
+			expectErr:  vetoErr,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			provider := migrationtest.NewMockDbProvider(ctrl)
+			provider.EXPECT().GetDb().AnyTimes()
+			provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+			provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords(tc.applied...), nil)
+			provider.EXPECT().MarkApplied(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			provider.EXPECT().DeleteApplied(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			m := NewMigrator(provider)
+			for _, name := range []string{"mig_001", "mig_002"} {
+				m.Add(
+					name,
+					func(db *sql.DB) error { return tc.upErr },
+					func(db *sql.DB) error { return nil },
+				)
+			}
+
+			calls := make([]string, 0)
+			m.AddHooks(recordingHooks(&calls))
+			m.AddHooks(tc.hooks)
+
+			done, err := tc.run(m)
+			assert.Equal(t, tc.expectErr, err)
+			assert.EqualValues(t, tc.expectDone, done)
+			assert.Equal(t, tc.expectCalls, calls)
+		})
+	}
+}
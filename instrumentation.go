@@ -0,0 +1,156 @@
+package mymigrate
+
+import (
+	"context"
+	"time"
+)
+
+// Names of metrics passed to Metrics
+const (
+	// MetricMigrationsApplied - counter of applied migrations
+	MetricMigrationsApplied = "mymigrate_migrations_applied_total"
+	// MetricMigrationsReverted - counter of reverted migrations
+	MetricMigrationsReverted = "mymigrate_migrations_reverted_total"
+	// MetricMigrationsFailed - counter of migrations that failed to apply or revert
+	MetricMigrationsFailed = "mymigrate_migrations_failed_total"
+	// MetricMigrationDuration - histogram of migration durations in seconds
+	MetricMigrationDuration = "mymigrate_migration_duration_seconds"
+	// MetricMigrationsPending - gauge of registered migrations that aren't applied yet
+	MetricMigrationsPending = "mymigrate_migrations_pending"
+)
+
+// Labels of metrics and attributes of spans
+const (
+	LabelMigration = "migration"
+	LabelDirection = "direction"
+	LabelMethod    = "method"
+)
+
+// Metrics receives measurements of migration runs.
+// The interface is neutral, so it can be implemented with Prometheus collectors or OpenTelemetry instruments.
+// Counters and the histogram have migration and direction labels, the gauge has none
+type Metrics interface {
+	IncCounter(name string, labels map[string]string)
+	ObserveHistogram(name string, value float64, labels map[string]string)
+	SetGauge(name string, value float64, labels map[string]string)
+}
+
+// Tracer starts spans around migration functions and database provider calls.
+// Migration functions are run in spans named "mymigrate.up" and "mymigrate.down",
+// provider calls are run in spans named "mymigrate.provider" with the method attribute.
+// The context returned by Start is passed to the traced call
+type Tracer interface {
+	Start(ctx context.Context, name string, attributes map[string]string) (context.Context, Span)
+}
+
+// Span is a span started by Tracer
+type Span interface {
+	// End ends the span. err is nil if the traced call succeeded
+	End(err error)
+}
+
+// nopMetrics is Metrics that drops measurements. Migrator uses it until metrics are set
+type nopMetrics struct{}
+
+func (nopMetrics) IncCounter(name string, labels map[string]string)                      {}
+func (nopMetrics) ObserveHistogram(name string, value float64, labels map[string]string) {}
+func (nopMetrics) SetGauge(name string, value float64, labels map[string]string)         {}
+
+// nopTracer is a Tracer that starts no spans. Migrator uses it until a tracer is set
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, name string, attributes map[string]string) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) End(err error) {}
+
+// SetMetrics sets receiver of migration metrics. Pass nil to turn metrics off
+func (m *Migrator) SetMetrics(metrics Metrics) {
+	if metrics == nil {
+		metrics = nopMetrics{}
+	}
+
+	m.metrics = metrics
+}
+
+// SetTracer sets a tracer of migration functions and database provider calls. Pass nil to turn tracing off
+func (m *Migrator) SetTracer(tracer Tracer) {
+	if tracer == nil {
+		tracer = nopTracer{}
+	}
+
+	m.tracer = tracer
+}
+
+// observeMigration updates metrics of the migration run in the direction
+func (m *Migrator) observeMigration(name string, direction Direction, duration time.Duration, err error) {
+	labels := map[string]string{
+		LabelMigration: name,
+		LabelDirection: string(direction),
+	}
+
+	m.metrics.ObserveHistogram(MetricMigrationDuration, duration.Seconds(), labels)
+
+	switch {
+	case err != nil:
+		m.metrics.IncCounter(MetricMigrationsFailed, labels)
+	case direction == DirectionUp:
+		m.metrics.IncCounter(MetricMigrationsApplied, labels)
+	default:
+		m.metrics.IncCounter(MetricMigrationsReverted, labels)
+	}
+}
+
+// observePending sets the gauge of pending migrations according to the history records
+func (m *Migrator) observePending(records []HistoryRecord) {
+	m.metrics.SetGauge(MetricMigrationsPending, float64(len(m.newNames(records))), map[string]string{})
+}
+
+// traceMigration runs the migration function f in a span
+func (m *Migrator) traceMigration(ctx context.Context, name string, direction Direction, f func(ctx context.Context) error) error {
+	ctx, span := m.tracer.Start(ctx, "mymigrate."+string(direction), map[string]string{
+		LabelMigration: name,
+		LabelDirection: string(direction),
+	})
+
+	err := f(ctx)
+	span.End(err)
+
+	return err
+}
+
+// updatePending reads the history to set the gauge of pending migrations after a run.
+// The history isn't read if metrics aren't set
+func (m *Migrator) updatePending(ctx context.Context) {
+	if _, ok := m.metrics.(nopMetrics); ok {
+		return
+	}
+
+	records, err := m.getApplied(ctx, m.provider)
+	if err == nil {
+		m.observePending(records)
+	}
+}
+
+// providerCall runs a single database provider call f in a span with the query timeout
+func (m *Migrator) providerCall(ctx context.Context, method string, f func(ctx context.Context) error) error {
+	ctx, cancel := m.queryContext(ctx)
+	defer cancel()
+
+	return m.traceProviderCall(ctx, method, f)
+}
+
+// traceProviderCall runs a database provider call f in a span
+func (m *Migrator) traceProviderCall(ctx context.Context, method string, f func(ctx context.Context) error) error {
+	ctx, span := m.tracer.Start(ctx, "mymigrate.provider", map[string]string{
+		LabelMethod: method,
+	})
+
+	err := f(ctx)
+	span.End(err)
+
+	return err
+}
@@ -0,0 +1,129 @@
+package mymigrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iamsalnikov/mymigrate/migrationtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMetrics keeps counters, number of histogram observations and gauges by name and labels
+type recordingMetrics struct {
+	counters     map[string]int
+	observations map[string]int
+	gauges       map[string]float64
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{
+		counters:     map[string]int{},
+		observations: map[string]int{},
+		gauges:       map[string]float64{},
+	}
+}
+
+func metricKey(name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key, value := range labels {
+		keys = append(keys, key+"="+value)
+	}
+
+	sort.Strings(keys)
+
+	return fmt.Sprintf("%s%v", name, keys)
+}
+
+func (m *recordingMetrics) IncCounter(name string, labels map[string]string) {
+	m.counters[metricKey(name, labels)]++
+}
+
+func (m *recordingMetrics) ObserveHistogram(name string, value float64, labels map[string]string) {
+	m.observations[metricKey(name, labels)]++
+}
+
+func (m *recordingMetrics) SetGauge(name string, value float64, labels map[string]string) {
+	m.gauges[metricKey(name, labels)] = value
+}
+
+// recordingTracer keeps ended spans with their attributes and errors
+type recordingTracer struct {
+	spans []string
+}
+
+type recordingSpan struct {
+	tracer *recordingTracer
+	span   string
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, attributes map[string]string) (context.Context, Span) {
+	return ctx, &recordingSpan{tracer: t, span: metricKey(name, attributes)}
+}
+
+func (s *recordingSpan) End(err error) {
+	if err != nil {
+		s.span += " " + err.Error()
+	}
+
+	s.tracer.spans = append(s.tracer.spans, s.span)
+}
+
+func TestMigrator_Instrumentation(t *testing.T) {
+	upErr := errors.New("up error")
+
+	ctrl := gomock.NewController(t)
+	provider := migrationtest.NewMockDbProvider(ctrl)
+	provider.EXPECT().GetDb().AnyTimes()
+	provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords(), nil)
+	provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords("mig_001"), nil)
+	provider.EXPECT().MarkApplied(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	m := NewMigrator(provider)
+	m.Add("mig_001", func(db *sql.DB) error { return nil }, nil)
+	m.Add("mig_002", func(db *sql.DB) error { return upErr }, nil)
+	m.Add("mig_003", func(db *sql.DB) error { return nil }, nil)
+
+	metrics := newRecordingMetrics()
+	m.SetMetrics(metrics)
+	tracer := &recordingTracer{}
+	m.SetTracer(tracer)
+
+	applied, err := m.Apply()
+	assert.Equal(t, upErr, err)
+	assert.Equal(t, []string{"mig_001"}, applied)
+
+	assert.Equal(t, map[string]int{
+		"mymigrate_migrations_applied_total[direction=up migration=mig_001]": 1,
+		"mymigrate_migrations_failed_total[direction=up migration=mig_002]":  1,
+	}, metrics.counters)
+	assert.Equal(t, map[string]int{
+		"mymigrate_migration_duration_seconds[direction=up migration=mig_001]": 1,
+		"mymigrate_migration_duration_seconds[direction=up migration=mig_002]": 1,
+	}, metrics.observations)
+	assert.Equal(t, map[string]float64{
+		"mymigrate_migrations_pending[]": 2,
+	}, metrics.gauges)
+
+	assert.Equal(t, []string{
+		"mymigrate.provider[method=CreateMigrationsTable]",
+		"mymigrate.provider[method=GetApplied]",
+		"mymigrate.provider[method=CreateMigrationsTable]",
+		"mymigrate.provider[method=MarkApplied]",
+		"mymigrate.up[direction=up migration=mig_001]",
+		"mymigrate.provider[method=CreateMigrationsTable]",
+		"mymigrate.provider[method=MarkApplied]",
+		"mymigrate.provider[method=CreateMigrationsTable]",
+		"mymigrate.provider[method=MarkApplied]",
+		"mymigrate.up[direction=up migration=mig_002] up error",
+		"mymigrate.provider[method=CreateMigrationsTable]",
+		"mymigrate.provider[method=MarkApplied]",
+		"mymigrate.provider[method=CreateMigrationsTable]",
+		"mymigrate.provider[method=GetApplied]",
+	}, tracer.spans)
+}
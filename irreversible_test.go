@@ -0,0 +1,145 @@
+package mymigrate
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iamsalnikov/mymigrate/migrationtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrator_DownRefusesIrreversible(t *testing.T) {
+	noop := func(db *sql.DB) error { return nil }
+	testCases := map[string]struct {
+		register func(m *Migrator)
+		down     func(m *Migrator) ([]string, error)
+
+		expectReverted []string
+		expectErr      string
+	}{
+		"reversible range": {
+			register: func(m *Migrator) {
+				m.Add("mig_003", noop, noop, Irreversible())
+			},
+			down: func(m *Migrator) ([]string, error) {
+				return m.Down(1)
+			},
+			expectReverted: []string{"mig_004"},
+		},
+		"nil down function": {
+			register: func(m *Migrator) {
+				m.Add("mig_003", noop, nil)
+			},
+			down: func(m *Migrator) ([]string, error) {
+				return m.Down(0)
+			},
+			expectErr: "migration is irreversible: can't revert migration 'mig_003'",
+		},
+		"irreversible option": {
+			register: func(m *Migrator) {
+				m.Add("mig_003", noop, noop, Irreversible())
+			},
+			down: func(m *Migrator) ([]string, error) {
+				return m.DownTo("mig_001")
+			},
+			expectErr: "migration is irreversible: can't revert migration 'mig_003'",
+		},
+		"irreversible SQL migration": {
+			register: func(m *Migrator) {
+				_ = m.AddFS(fstest.MapFS{
+					"mig_003.sql": {Data: []byte("-- +irreversible\n-- +up\nDELETE FROM users;\n-- +down\nSELECT 1;")},
+				}, ".")
+			},
+			down: func(m *Migrator) ([]string, error) {
+				return m.DownTo("mig_002")
+			},
+			expectErr: "migration is irreversible: can't revert migration 'mig_003'",
+		},
+		"SQL migration with empty down section": {
+			register: func(m *Migrator) {
+				_ = m.AddFS(fstest.MapFS{
+					"mig_003.sql": {Data: []byte("-- +up\nDELETE FROM users;\n-- +down\n")},
+				}, ".")
+			},
+			down: func(m *Migrator) ([]string, error) {
+				return m.DownTo("mig_002")
+			},
+			expectErr: "migration is irreversible: can't revert migration 'mig_003'",
+		},
+		"SQL migration without down file": {
+			register: func(m *Migrator) {
+				_ = m.AddFS(fstest.MapFS{
+					"mig_003.up.sql": {Data: []byte("DELETE FROM users;")},
+				}, ".")
+			},
+			down: func(m *Migrator) ([]string, error) {
+				return m.DownTo("mig_002")
+			},
+			expectErr: "migration is irreversible: can't revert migration 'mig_003'",
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			provider := migrationtest.NewMockDbProvider(ctrl)
+			provider.EXPECT().GetDb().AnyTimes()
+			provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+			provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords("mig_004", "mig_003", "mig_002", "mig_001"), nil)
+			provider.EXPECT().MarkApplied(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			provider.EXPECT().DeleteApplied(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			calls := make([]string, 0)
+			down := func(name string) DownFunc {
+				return func(db *sql.DB) error {
+					calls = append(calls, name)
+					return nil
+				}
+			}
+
+			m := NewMigrator(provider)
+			m.Add("mig_001", noop, down("mig_001"))
+			m.Add("mig_004", noop, down("mig_004"))
+			tc.register(m)
+			m.Add("mig_002", noop, down("mig_002"))
+
+			reverted, err := tc.down(m)
+			if len(tc.expectErr) > 0 {
+				assert.EqualError(t, err, tc.expectErr)
+				assert.True(t, errors.Is(err, ErrIrreversible))
+				assert.Nil(t, reverted)
+				assert.Empty(t, calls, "nothing should be reverted")
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectReverted, reverted)
+			assert.Equal(t, tc.expectReverted, calls)
+		})
+	}
+}
+
+func TestMigrator_StatusShowsIrreversible(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	provider := migrationtest.NewMockDbProvider(ctrl)
+	provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords("mig_001", "mig_000"), nil)
+
+	noop := func(db *sql.DB) error { return nil }
+	m := NewMigrator(provider)
+	m.Add("mig_001", noop, nil)
+	m.Add("mig_002", noop, noop, Irreversible())
+	m.Add("mig_003", noop, noop)
+
+	statuses, err := m.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, []MigrationStatus{
+		{Name: "mig_000", State: StateNotRegistered},
+		{Name: "mig_001", State: StateApplied, Irreversible: true},
+		{Name: "mig_002", State: StatePending, Irreversible: true},
+		{Name: "mig_003", State: StatePending},
+	}, statuses)
+}
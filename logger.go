@@ -0,0 +1,87 @@
+package mymigrate
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger is a structured logger of migration runs.
+// keysAndValues are pairs of field names and values, so *slog.Logger can be used as a Logger
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// nopLogger is a Logger that logs nothing. Migrator uses it until a logger is set
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, keysAndValues ...interface{}) {}
+func (nopLogger) Info(msg string, keysAndValues ...interface{})  {}
+func (nopLogger) Warn(msg string, keysAndValues ...interface{})  {}
+func (nopLogger) Error(msg string, keysAndValues ...interface{}) {}
+
+// StdLogger is a Logger that writes messages with key=value fields to a standard logger
+type StdLogger struct {
+	logger *log.Logger
+	debug  bool
+}
+
+// NewStdLogger creates a StdLogger writing to the logger. Debug messages are written only if debug is true
+func NewStdLogger(logger *log.Logger, debug bool) *StdLogger {
+	return &StdLogger{
+		logger: logger,
+		debug:  debug,
+	}
+}
+
+// Debug writes the message if the logger was created with debug
+func (l *StdLogger) Debug(msg string, keysAndValues ...interface{}) {
+	if l.debug {
+		l.write("DEBUG", msg, keysAndValues)
+	}
+}
+
+// Info writes the message
+func (l *StdLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.write("INFO", msg, keysAndValues)
+}
+
+// Warn writes the message
+func (l *StdLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.write("WARN", msg, keysAndValues)
+}
+
+// Error writes the message
+func (l *StdLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.write("ERROR", msg, keysAndValues)
+}
+
+func (l *StdLogger) write(level, msg string, keysAndValues []interface{}) {
+	var b strings.Builder
+	b.WriteString(level)
+	b.WriteString(" ")
+	b.WriteString(msg)
+
+	for i := 0; i < len(keysAndValues); i += 2 {
+		var value interface{} = "<missing>"
+		if i+1 < len(keysAndValues) {
+			value = keysAndValues[i+1]
+		}
+
+		fmt.Fprintf(&b, " %v=%q", keysAndValues[i], fmt.Sprint(value))
+	}
+
+	l.logger.Print(b.String())
+}
+
+// SetLogger sets a logger of migration runs. Pass nil to turn logging off
+func (m *Migrator) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = nopLogger{}
+	}
+
+	m.logger = logger
+}
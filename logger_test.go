@@ -0,0 +1,135 @@
+package mymigrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang/mock/gomock"
+	"github.com/iamsalnikov/mymigrate/migrationtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingLogger keeps logged messages with their level and fields except durations
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Debug(msg string, keysAndValues ...interface{}) {
+	l.record("debug", msg, keysAndValues)
+}
+
+func (l *recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.record("info", msg, keysAndValues)
+}
+
+func (l *recordingLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.record("warn", msg, keysAndValues)
+}
+
+func (l *recordingLogger) Error(msg string, keysAndValues ...interface{}) {
+	l.record("error", msg, keysAndValues)
+}
+
+func (l *recordingLogger) record(level, msg string, keysAndValues []interface{}) {
+	message := level + " " + msg
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if keysAndValues[i] == "duration" {
+			continue
+		}
+
+		message += fmt.Sprintf(" %v=%v", keysAndValues[i], keysAndValues[i+1])
+	}
+
+	l.messages = append(l.messages, message)
+}
+
+func TestMigrator_Logger(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	ctrl := gomock.NewController(t)
+	provider := migrationtest.NewMockDbProvider(ctrl)
+	provider.EXPECT().GetDb().Return(db).AnyTimes()
+	provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords(), nil)
+	provider.EXPECT().MarkApplied(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	mock.ExpectExec("CREATE TABLE users (id INT);").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO users VALUES (1);").WillReturnError(errors.New("duplicate key"))
+
+	m := NewMigrator(provider)
+	logger := &recordingLogger{}
+	m.SetLogger(logger)
+
+	err = m.AddFS(fstest.MapFS{
+		"001_users.sql": {Data: []byte("-- +up\n-- +notransaction\nCREATE TABLE users (id INT);\nINSERT INTO users VALUES (1);\n")},
+	}, ".")
+	assert.NoError(t, err)
+
+	_, err = m.ApplyContext(context.Background())
+	assert.EqualError(t, err, "duplicate key")
+	assert.Equal(t, []string{
+		"info migration run started direction=up",
+		"debug preparing migration history table",
+		"info migrations to run direction=up migrations=[001_users]",
+		"info migration started migration=001_users direction=up",
+		"debug preparing migration history table",
+		"debug executing statement migration=001_users statement=CREATE TABLE users (id INT);",
+		"debug executing statement migration=001_users statement=INSERT INTO users VALUES (1);",
+		"debug preparing migration history table",
+		"error migration failed migration=001_users direction=up error=duplicate key",
+		"error migration run failed direction=up migrations=[] error=duplicate key",
+	}, logger.messages)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStdLogger(t *testing.T) {
+	testCases := map[string]struct {
+		debug bool
+		log   func(l *StdLogger)
+
+		expectOutput string
+	}{
+		"info with fields": {
+			log: func(l *StdLogger) {
+				l.Info("migration started", "migration", "001_users", "direction", DirectionUp)
+			},
+			expectOutput: "INFO migration started migration=\"001_users\" direction=\"up\"\n",
+		},
+		"error with odd number of fields": {
+			log: func(l *StdLogger) {
+				l.Error("migration failed", "error", errors.New("boom"), "migration")
+			},
+			expectOutput: "ERROR migration failed error=\"boom\" migration=\"<missing>\"\n",
+		},
+		"debug is off": {
+			log: func(l *StdLogger) {
+				l.Debug("executing statement", "statement", "SELECT 1")
+			},
+			expectOutput: "",
+		},
+		"debug is on": {
+			debug: true,
+			log: func(l *StdLogger) {
+				l.Debug("executing statement", "statement", "SELECT 1")
+			},
+			expectOutput: "DEBUG executing statement statement=\"SELECT 1\"\n",
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			var buf bytes.Buffer
+			tc.log(NewStdLogger(log.New(&buf, "", 0), tc.debug))
+
+			assert.Equal(t, tc.expectOutput, buf.String())
+		})
+	}
+}
@@ -3,137 +3,153 @@ package mymigrate
 import (
 	"context"
 	"fmt"
-	"sort"
+	"io/fs"
 	"time"
 )
 
-var (
-	// set of project's migrations
-	migrations = make(map[string]mig)
-	// database provider
-	dbProvider DbProvider
-	// function to get list of applied migrations
-	getApplied = defaultAppliedFunc
-	// function to mark migration as aplied
-	markApplied = defaultMarkAppliedFunc
-	// function to down migrations
-	down = defaultDownFunc
-)
-
-func defaultAppliedFunc(provider DbProvider) ([]string, error) {
-	err := provider.CreateMigrationsTable()
-	if err != nil {
-		return nil, err
-	}
-
-	ctx, _ := context.WithTimeout(context.Background(), 30*time.Second)
+// default migrator that is used by package-level functions
+var defaultMigrator = NewMigrator(nil)
 
-	return provider.GetApplied(ctx)
+// Add adds mig to queue
+// Use this function in init()
+func Add(name string, up UpFunc, down DownFunc, opts ...MigrationOption) {
+	defaultMigrator.Add(name, up, down, opts...)
 }
 
-func defaultMarkAppliedFunc(provider DbProvider, name string) error {
-	err := provider.CreateMigrationsTable()
-	if err != nil {
-		return err
-	}
+// AddContext adds mig which functions accept context to queue
+// Use this function in init()
+func AddContext(name string, up UpContextFunc, down DownContextFunc, opts ...MigrationOption) {
+	defaultMigrator.AddContext(name, up, down, opts...)
+}
 
-	ctx, _ := context.WithTimeout(context.Background(), 30*time.Second)
+// AddTx adds mig that should be run inside a transaction to queue
+// Use this function in init()
+func AddTx(name string, up TxUpFunc, down TxDownFunc, opts ...MigrationOption) {
+	defaultMigrator.AddTx(name, up, down, opts...)
+}
 
-	return provider.MarkApplied(ctx, name, time.Now())
+// AddTxContext adds mig that should be run inside a transaction and which functions accept context to queue
+// Use this function in init()
+func AddTxContext(name string, up TxUpContextFunc, down TxDownContextFunc, opts ...MigrationOption) {
+	defaultMigrator.AddTxContext(name, up, down, opts...)
 }
 
-func defaultDownFunc(provider DbProvider, names []string) ([]string, error) {
-	err := provider.CreateMigrationsTable()
-	if err != nil {
-		return nil, err
-	}
+// AddDir adds SQL migrations from the directory to queue
+func AddDir(dir string) error {
+	return defaultMigrator.AddDir(dir)
+}
 
-	downed := make([]string, 0, len(names))
-	for _, name := range names {
-		mig, ok := migrations[name]
-		if !ok {
-			return downed, fmt.Errorf("can't find migration '%s'", name)
-		}
-
-		err = mig.down(provider.GetDb())
-		if err != nil {
-			return downed, err
-		}
-
-		ctx, _ := context.WithTimeout(context.Background(), 30*time.Second)
-		err = provider.DeleteApplied(ctx, name)
-		if err != nil {
-			return downed, err
-		}
-
-		downed = append(downed, name)
-	}
+// AddFS adds SQL migrations from the dir of fsys to queue
+// It works well with embed.FS
+func AddFS(fsys fs.FS, dir string) error {
+	return defaultMigrator.AddFS(fsys, dir)
+}
 
-	return downed, nil
+// Dependencies returns names of registered migrations with names of migrations they depend on
+func Dependencies() map[string][]string {
+	return defaultMigrator.Dependencies()
 }
 
-// Add adds mig to queue
-// Use this function in init()
-func Add(name string, up UpFunc, down DownFunc) {
-	migrations[name] = mig{
-		name: name,
-		up:   up,
-		down: down,
-	}
+// Validate func returns an error describing every problem of registered migrations
+func Validate() error {
+	return defaultMigrator.Validate()
 }
 
 // SetDatabaseProvider sets a DbProvider that we should use for applying migrations
 func SetDatabaseProvider(provider DbProvider) {
-	dbProvider = provider
+	defaultMigrator.SetDatabaseProvider(provider)
 }
 
-// NewNames returns names of new migrations
-func NewNames() ([]string, error) {
-	appliedNames, err := getApplied(dbProvider)
-	if err != nil {
-		return nil, err
-	}
+// SetLockTimeout sets how long Apply and Down should wait for the migration lock.
+// Pass 0 to wait as long as the context allows
+func SetLockTimeout(timeout time.Duration) {
+	defaultMigrator.SetLockTimeout(timeout)
+}
 
-	applied := map[string]bool{}
-	for _, name := range appliedNames {
-		applied[name] = true
-	}
+// SetOutOfOrderPolicy sets what Apply does with new migrations that sort before the latest applied one
+func SetOutOfOrderPolicy(policy OutOfOrderPolicy) {
+	defaultMigrator.SetOutOfOrderPolicy(policy)
+}
 
-	result := make([]string, 0)
-	for name := range migrations {
-		if !applied[name] {
-			result = append(result, name)
-		}
-	}
+// SetAppVersion sets version of the application that is recorded to the history with every migration
+func SetAppVersion(version string) {
+	defaultMigrator.SetAppVersion(version)
+}
 
-	sort.Strings(result)
+// AddHooks adds callbacks around migration runs
+func AddHooks(hooks Hooks) {
+	defaultMigrator.AddHooks(hooks)
+}
 
-	return result, nil
+// SetLogger sets a logger of migration runs. Pass nil to turn logging off
+func SetLogger(logger Logger) {
+	defaultMigrator.SetLogger(logger)
+}
+
+// SetMetrics sets receiver of migration metrics. Pass nil to turn metrics off
+func SetMetrics(metrics Metrics) {
+	defaultMigrator.SetMetrics(metrics)
+}
+
+// SetTracer sets a tracer of migration functions and database provider calls. Pass nil to turn tracing off
+func SetTracer(tracer Tracer) {
+	defaultMigrator.SetTracer(tracer)
+}
+
+// SetQueryTimeout sets timeout of a single database provider call
+// Pass 0 to rely only on the context passed to functions
+func SetQueryTimeout(timeout time.Duration) {
+	defaultMigrator.SetQueryTimeout(timeout)
+}
+
+// NewNames returns names of new migrations
+func NewNames() ([]string, error) {
+	return defaultMigrator.NewNames()
+}
+
+// NewNamesContext returns names of new migrations
+func NewNamesContext(ctx context.Context) ([]string, error) {
+	return defaultMigrator.NewNamesContext(ctx)
 }
 
 // Apply func applies migrations
 func Apply() ([]string, error) {
-	newNames, err := NewNames()
-	if err != nil {
-		return nil, err
-	}
+	return defaultMigrator.Apply()
+}
 
-	applied := make([]string, 0, len(newNames))
-	for _, name := range newNames {
-		err = migrations[name].up(dbProvider.GetDb())
-		if err != nil {
-			return applied, err
-		}
+// ApplyContext func applies migrations and stops as soon as ctx is done
+func ApplyContext(ctx context.Context) ([]string, error) {
+	return defaultMigrator.ApplyContext(ctx)
+}
 
-		err = markApplied(dbProvider, name)
-		if err != nil {
-			return applied, err
-		}
+// ApplyTo func applies migrations up to and including the named one
+func ApplyTo(name string) ([]string, error) {
+	return defaultMigrator.ApplyTo(name)
+}
 
-		applied = append(applied, name)
-	}
+// ApplyToContext func applies migrations up to and including the named one and stops as soon as ctx is done
+func ApplyToContext(ctx context.Context, name string) ([]string, error) {
+	return defaultMigrator.ApplyToContext(ctx, name)
+}
 
-	return applied, nil
+// Plan func returns a plan of Apply without applying migrations
+func Plan() (MigrationPlan, error) {
+	return defaultMigrator.Plan()
+}
+
+// PlanContext func returns a plan of ApplyContext without applying migrations
+func PlanContext(ctx context.Context) (MigrationPlan, error) {
+	return defaultMigrator.PlanContext(ctx)
+}
+
+// PlanTo func returns a plan of ApplyTo without applying migrations
+func PlanTo(name string) (MigrationPlan, error) {
+	return defaultMigrator.PlanTo(name)
+}
+
+// PlanToContext func returns a plan of ApplyToContext without applying migrations
+func PlanToContext(ctx context.Context, name string) (MigrationPlan, error) {
+	return defaultMigrator.PlanToContext(ctx, name)
 }
 
 // datedMigrationName returns dated migration name
@@ -175,28 +191,160 @@ func init() {
 	return fmt.Sprintf(template, pkg, name), name
 }
 
+// SQLTemplate func returns a new SQL migration template and the name of the migration
+// The template should be saved to NAME.sql file
+func SQLTemplate(name string) (string, string) {
+	template := `-- +up
+-- TODO: write UP logic
+
+-- +down
+-- TODO: write down logic
+`
+
+	return template, datedMigrationName(name)
+}
+
 // History func returns chronological history of applied migrations
-func History() ([]string, error) {
-	return getApplied(dbProvider)
+func History() ([]HistoryRecord, error) {
+	return defaultMigrator.History()
+}
+
+// HistoryContext func returns chronological history of applied migrations
+func HistoryContext(ctx context.Context) ([]HistoryRecord, error) {
+	return defaultMigrator.HistoryContext(ctx)
+}
+
+// Status func returns states of registered and applied migrations
+func Status() ([]MigrationStatus, error) {
+	return defaultMigrator.Status()
+}
+
+// StatusContext func returns states of registered and applied migrations
+func StatusContext(ctx context.Context) ([]MigrationStatus, error) {
+	return defaultMigrator.StatusContext(ctx)
+}
+
+// Verify func returns drifts between applied and registered migrations
+func Verify() ([]Drift, error) {
+	return defaultMigrator.Verify()
+}
+
+// VerifyContext func returns drifts between applied and registered migrations
+func VerifyContext(ctx context.Context) ([]Drift, error) {
+	return defaultMigrator.VerifyContext(ctx)
+}
+
+// ForceApplied func marks the migration as applied without running it
+func ForceApplied(name string) error {
+	return defaultMigrator.ForceApplied(name)
+}
+
+// ForceAppliedContext func marks the migration as applied without running it
+func ForceAppliedContext(ctx context.Context, name string) error {
+	return defaultMigrator.ForceAppliedContext(ctx, name)
+}
+
+// Baseline func marks registered migrations up to and including the named one as applied without running them
+func Baseline(upTo string) ([]string, error) {
+	return defaultMigrator.Baseline(upTo)
+}
+
+// BaselineContext func marks registered migrations up to and including the named one as applied without running them
+func BaselineContext(ctx context.Context, upTo string) ([]string, error) {
+	return defaultMigrator.BaselineContext(ctx, upTo)
+}
+
+// ForceReverted func deletes the migration from the history without running it
+func ForceReverted(name string) error {
+	return defaultMigrator.ForceReverted(name)
+}
+
+// ForceRevertedContext func deletes the migration from the history without running it
+func ForceRevertedContext(ctx context.Context, name string) error {
+	return defaultMigrator.ForceRevertedContext(ctx, name)
 }
 
 // Down func reverts particular number of migrations
 // Pass 0 as a number to revert all migrations
 func Down(number int) ([]string, error) {
-	appliedNames, err := getApplied(dbProvider)
-	if err != nil {
-		return nil, err
-	}
+	return defaultMigrator.Down(number)
+}
 
-	if len(appliedNames) == 0 {
-		return []string{}, nil
-	}
+// DownContext func reverts particular number of migrations and stops as soon as ctx is done
+// Pass 0 as a number to revert all migrations
+func DownContext(ctx context.Context, number int) ([]string, error) {
+	return defaultMigrator.DownContext(ctx, number)
+}
 
-	endIndex := number
-	if number >= len(appliedNames) || number == 0 {
-		endIndex = len(appliedNames)
-	}
+// DownTo func reverts all migrations applied after the named one
+func DownTo(name string) ([]string, error) {
+	return defaultMigrator.DownTo(name)
+}
+
+// DownToContext func reverts all migrations applied after the named one and stops as soon as ctx is done
+func DownToContext(ctx context.Context, name string) ([]string, error) {
+	return defaultMigrator.DownToContext(ctx, name)
+}
+
+// Redo func reverts particular number of the latest applied migrations and applies the same migrations again.
+// Pass 0 as a number to redo all migrations
+func Redo(number int) ([]string, []string, error) {
+	return defaultMigrator.Redo(number)
+}
+
+// RedoContext func reverts particular number of the latest applied migrations and applies them again
+// and stops as soon as ctx is done
+func RedoContext(ctx context.Context, number int) ([]string, []string, error) {
+	return defaultMigrator.RedoContext(ctx, number)
+}
+
+// RollbackBatch func reverts migrations of the latest batch, i.e. migrations applied by the latest Apply run
+func RollbackBatch() ([]string, error) {
+	return defaultMigrator.RollbackBatch()
+}
+
+// RollbackBatchContext func reverts migrations of the latest batch and stops as soon as ctx is done
+func RollbackBatchContext(ctx context.Context) ([]string, error) {
+	return defaultMigrator.RollbackBatchContext(ctx)
+}
+
+// RollbackBatches func reverts migrations of particular number of the latest batches
+func RollbackBatches(number int) ([]string, error) {
+	return defaultMigrator.RollbackBatches(number)
+}
+
+// RollbackBatchesContext func reverts migrations of particular number of the latest batches
+// and stops as soon as ctx is done
+func RollbackBatchesContext(ctx context.Context, number int) ([]string, error) {
+	return defaultMigrator.RollbackBatchesContext(ctx, number)
+}
+
+// RollbackPlan func returns a plan of RollbackBatches without reverting migrations
+func RollbackPlan(number int) (MigrationPlan, error) {
+	return defaultMigrator.RollbackPlan(number)
+}
+
+// RollbackPlanContext func returns a plan of RollbackBatchesContext without reverting migrations
+func RollbackPlanContext(ctx context.Context, number int) (MigrationPlan, error) {
+	return defaultMigrator.RollbackPlanContext(ctx, number)
+}
+
+// DownPlan func returns a plan of Down without reverting migrations
+func DownPlan(number int) (MigrationPlan, error) {
+	return defaultMigrator.DownPlan(number)
+}
+
+// DownPlanContext func returns a plan of DownContext without reverting migrations
+func DownPlanContext(ctx context.Context, number int) (MigrationPlan, error) {
+	return defaultMigrator.DownPlanContext(ctx, number)
+}
+
+// DownToPlan func returns a plan of DownTo without reverting migrations
+func DownToPlan(name string) (MigrationPlan, error) {
+	return defaultMigrator.DownToPlan(name)
+}
 
-	namesToDown := appliedNames[:endIndex]
-	return down(dbProvider, namesToDown)
+// DownToPlanContext func returns a plan of DownToContext without reverting migrations
+func DownToPlanContext(ctx context.Context, name string) (MigrationPlan, error) {
+	return defaultMigrator.DownToPlanContext(ctx, name)
 }
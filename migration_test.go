@@ -1,6 +1,7 @@
 package mymigrate
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -71,10 +72,11 @@ func Test_MyMigrateNewNames(t *testing.T) {
 		resetMigrations()
 		resetAppliedFunc()
 		resetMarkAppliedFunc()
+		resetMarkDirtyFunc()
 
 		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
-			getApplied = func(provider DbProvider) ([]string, error) {
-				return c.appliedNames, c.applyErr
+			defaultMigrator.getApplied = func(ctx context.Context, provider DbProvider) ([]HistoryRecord, error) {
+				return historyRecords(c.appliedNames...), c.applyErr
 			}
 
 			for _, name := range c.newNames {
@@ -243,6 +245,7 @@ func Test_MyMigrateApply(t *testing.T) {
 		resetMigrations()
 		resetAppliedFunc()
 		resetMarkAppliedFunc()
+		resetMarkDirtyFunc()
 
 		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
 			ctrl := gomock.NewController(t)
@@ -253,12 +256,13 @@ func Test_MyMigrateApply(t *testing.T) {
 
 			// we've already tested NewNames() function
 			// so, here we will return always empty slice
-			getApplied = func(provider DbProvider) ([]string, error) {
-				return []string{}, c.applyErr
+			defaultMigrator.getApplied = func(ctx context.Context, provider DbProvider) ([]HistoryRecord, error) {
+				return historyRecords(), c.applyErr
 			}
 
 			markedCall := make(map[string]bool)
-			markApplied = func(provider DbProvider, name string) error {
+			defaultMigrator.markApplied = func(ctx context.Context, provider DbProvider, record HistoryRecord) error {
+				name := record.Name
 				if !c.expectMarkedCall[name] {
 					t.Errorf("I didn't excpect that mig '%s' will be marked as aplied", name)
 				}
@@ -267,6 +271,9 @@ func Test_MyMigrateApply(t *testing.T) {
 
 				return c.markAppliedErr
 			}
+			defaultMigrator.markDirty = func(ctx context.Context, provider DbProvider, record HistoryRecord) error {
+				return nil
+			}
 
 			for name, mig := range c.migrations {
 				Add(name, mig.up, mig.down)
@@ -373,13 +380,17 @@ func Test_MyMigrateDown(t *testing.T) {
 		t.Run(tcName, func(t *testing.T) {
 			defer reset()
 
-			getApplied = func(provider DbProvider) ([]string, error) {
-				return tc.applied, tc.appliedErr
+			for _, name := range []string{"mig_001", "mig_002", "mig_003"} {
+				Add(name, func(db *sql.DB) error { return nil }, func(db *sql.DB) error { return nil })
+			}
+
+			defaultMigrator.getApplied = func(ctx context.Context, provider DbProvider) ([]HistoryRecord, error) {
+				return historyRecords(tc.applied...), tc.appliedErr
 			}
 
 			isDownCalled := false
-			down = func(provider DbProvider, names []string) ([]string, error) {
-				assert.EqualValues(t, tc.expDownNames, names, "check on expected migrations to down")
+			defaultMigrator.down = func(ctx context.Context, provider DbProvider, records []HistoryRecord) ([]string, error) {
+				assert.EqualValues(t, tc.expDownNames, recordNames(records), "check on expected migrations to down")
 				isDownCalled = true
 				return tc.expDownNames, tc.downErr
 			}
@@ -8,9 +8,9 @@ import (
 	context "context"
 	sql "database/sql"
 	reflect "reflect"
-	time "time"
 
 	gomock "github.com/golang/mock/gomock"
+	provider "github.com/iamsalnikov/mymigrate/provider"
 )
 
 // MockDbProvider is a mock of DbProvider interface.
@@ -37,17 +37,17 @@ func (m *MockDbProvider) EXPECT() *MockDbProviderMockRecorder {
 }
 
 // CreateMigrationsTable mocks base method.
-func (m *MockDbProvider) CreateMigrationsTable() error {
+func (m *MockDbProvider) CreateMigrationsTable(arg0 context.Context) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateMigrationsTable")
+	ret := m.ctrl.Call(m, "CreateMigrationsTable", arg0)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CreateMigrationsTable indicates an expected call of CreateMigrationsTable.
-func (mr *MockDbProviderMockRecorder) CreateMigrationsTable() *gomock.Call {
+func (mr *MockDbProviderMockRecorder) CreateMigrationsTable(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMigrationsTable", reflect.TypeOf((*MockDbProvider)(nil).CreateMigrationsTable))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMigrationsTable", reflect.TypeOf((*MockDbProvider)(nil).CreateMigrationsTable), arg0)
 }
 
 // DeleteApplied mocks base method.
@@ -65,10 +65,10 @@ func (mr *MockDbProviderMockRecorder) DeleteApplied(arg0, arg1 interface{}) *gom
 }
 
 // GetApplied mocks base method.
-func (m *MockDbProvider) GetApplied(arg0 context.Context) ([]string, error) {
+func (m *MockDbProvider) GetApplied(arg0 context.Context) ([]provider.HistoryRecord, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetApplied", arg0)
-	ret0, _ := ret[0].([]string)
+	ret0, _ := ret[0].([]provider.HistoryRecord)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -94,15 +94,188 @@ func (mr *MockDbProviderMockRecorder) GetDb() *gomock.Call {
 }
 
 // MarkApplied mocks base method.
-func (m *MockDbProvider) MarkApplied(arg0 context.Context, arg1 string, arg2 time.Time) error {
+func (m *MockDbProvider) MarkApplied(arg0 context.Context, arg1 provider.HistoryRecord) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MarkApplied", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "MarkApplied", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // MarkApplied indicates an expected call of MarkApplied.
-func (mr *MockDbProviderMockRecorder) MarkApplied(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockDbProviderMockRecorder) MarkApplied(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkApplied", reflect.TypeOf((*MockDbProvider)(nil).MarkApplied), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkApplied", reflect.TypeOf((*MockDbProvider)(nil).MarkApplied), arg0, arg1)
+}
+
+// MockTxProvider is a mock of TxProvider interface.
+type MockTxProvider struct {
+	ctrl     *gomock.Controller
+	recorder *MockTxProviderMockRecorder
+}
+
+// MockTxProviderMockRecorder is the mock recorder for MockTxProvider.
+type MockTxProviderMockRecorder struct {
+	mock *MockTxProvider
+}
+
+// NewMockTxProvider creates a new mock instance.
+func NewMockTxProvider(ctrl *gomock.Controller) *MockTxProvider {
+	mock := &MockTxProvider{ctrl: ctrl}
+	mock.recorder = &MockTxProviderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTxProvider) EXPECT() *MockTxProviderMockRecorder {
+	return m.recorder
+}
+
+// CreateMigrationsTable mocks base method.
+func (m *MockTxProvider) CreateMigrationsTable(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMigrationsTable", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateMigrationsTable indicates an expected call of CreateMigrationsTable.
+func (mr *MockTxProviderMockRecorder) CreateMigrationsTable(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMigrationsTable", reflect.TypeOf((*MockTxProvider)(nil).CreateMigrationsTable), arg0)
+}
+
+// DeleteApplied mocks base method.
+func (m *MockTxProvider) DeleteApplied(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteApplied", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteApplied indicates an expected call of DeleteApplied.
+func (mr *MockTxProviderMockRecorder) DeleteApplied(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteApplied", reflect.TypeOf((*MockTxProvider)(nil).DeleteApplied), arg0, arg1)
+}
+
+// DeleteAppliedTx mocks base method.
+func (m *MockTxProvider) DeleteAppliedTx(arg0 context.Context, arg1 *sql.Tx, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAppliedTx", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAppliedTx indicates an expected call of DeleteAppliedTx.
+func (mr *MockTxProviderMockRecorder) DeleteAppliedTx(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAppliedTx", reflect.TypeOf((*MockTxProvider)(nil).DeleteAppliedTx), arg0, arg1, arg2)
+}
+
+// GetApplied mocks base method.
+func (m *MockTxProvider) GetApplied(arg0 context.Context) ([]provider.HistoryRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApplied", arg0)
+	ret0, _ := ret[0].([]provider.HistoryRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApplied indicates an expected call of GetApplied.
+func (mr *MockTxProviderMockRecorder) GetApplied(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApplied", reflect.TypeOf((*MockTxProvider)(nil).GetApplied), arg0)
+}
+
+// GetDb mocks base method.
+func (m *MockTxProvider) GetDb() *sql.DB {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDb")
+	ret0, _ := ret[0].(*sql.DB)
+	return ret0
+}
+
+// GetDb indicates an expected call of GetDb.
+func (mr *MockTxProviderMockRecorder) GetDb() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDb", reflect.TypeOf((*MockTxProvider)(nil).GetDb))
+}
+
+// MarkApplied mocks base method.
+func (m *MockTxProvider) MarkApplied(arg0 context.Context, arg1 provider.HistoryRecord) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkApplied", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkApplied indicates an expected call of MarkApplied.
+func (mr *MockTxProviderMockRecorder) MarkApplied(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkApplied", reflect.TypeOf((*MockTxProvider)(nil).MarkApplied), arg0, arg1)
+}
+
+// MarkAppliedTx mocks base method.
+func (m *MockTxProvider) MarkAppliedTx(arg0 context.Context, arg1 *sql.Tx, arg2 provider.HistoryRecord) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkAppliedTx", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkAppliedTx indicates an expected call of MarkAppliedTx.
+func (mr *MockTxProviderMockRecorder) MarkAppliedTx(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkAppliedTx", reflect.TypeOf((*MockTxProvider)(nil).MarkAppliedTx), arg0, arg1, arg2)
+}
+
+// MockLocker is a mock of Locker interface.
+type MockLocker struct {
+	ctrl     *gomock.Controller
+	recorder *MockLockerMockRecorder
+}
+
+// MockLockerMockRecorder is the mock recorder for MockLocker.
+type MockLockerMockRecorder struct {
+	mock *MockLocker
+}
+
+// NewMockLocker creates a new mock instance.
+func NewMockLocker(ctrl *gomock.Controller) *MockLocker {
+	mock := &MockLocker{ctrl: ctrl}
+	mock.recorder = &MockLockerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLocker) EXPECT() *MockLockerMockRecorder {
+	return m.recorder
+}
+
+// Lock mocks base method.
+func (m *MockLocker) Lock(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lock", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Lock indicates an expected call of Lock.
+func (mr *MockLockerMockRecorder) Lock(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lock", reflect.TypeOf((*MockLocker)(nil).Lock), arg0)
+}
+
+// Unlock mocks base method.
+func (m *MockLocker) Unlock(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unlock", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unlock indicates an expected call of Unlock.
+func (mr *MockLockerMockRecorder) Unlock(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unlock", reflect.TypeOf((*MockLocker)(nil).Unlock), arg0)
 }
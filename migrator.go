@@ -0,0 +1,768 @@
+package mymigrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+)
+
+// Migrator holds a set of migrations and a database provider to apply them to.
+// Use several migrators to drive several databases with different migration sets
+type Migrator struct {
+	// set of migrator's migrations
+	migrations map[string]mig
+	// problems of added migrations reported by Validate
+	problems []string
+	// database provider
+	provider DbProvider
+	// function to get list of applied migrations
+	getApplied func(ctx context.Context, provider DbProvider) ([]HistoryRecord, error)
+	// function to mark migration as aplied
+	markApplied func(ctx context.Context, provider DbProvider, record HistoryRecord) error
+	// function to mark migration as dirty before running it or after it failed
+	markDirty func(ctx context.Context, provider DbProvider, record HistoryRecord) error
+	// function to down migrations of history records
+	down func(ctx context.Context, provider DbProvider, records []HistoryRecord) ([]string, error)
+	// how long to wait for the migration lock
+	lockTimeout time.Duration
+	// timeout of a single database provider call
+	queryTimeout time.Duration
+	// version of the application recorded to the history
+	appVersion string
+	// host and OS user recorded to the history
+	hostname string
+	osUser   string
+	// what Apply does with migrations that sort before the latest applied one
+	outOfOrderPolicy OutOfOrderPolicy
+	// callbacks around migration runs
+	hooks []Hooks
+	// instrumentation of migration runs
+	logger  Logger
+	metrics Metrics
+	tracer  Tracer
+}
+
+// ErrIrreversible is returned by Down and DownTo if an irreversible migration should be reverted.
+// Nothing is reverted in this case
+var ErrIrreversible = errors.New("migration is irreversible")
+
+// ErrDirty is returned by Apply and Down while the database is dirty,
+// i.e. a non-transactional migration failed in the middle and the database is in an unknown state
+var ErrDirty = errors.New("database is dirty")
+
+// DefaultLockTimeout - how long Apply and Down wait for the migration lock by default
+const DefaultLockTimeout = 10 * time.Minute
+
+// DefaultQueryTimeout - timeout of a single database provider call by default
+const DefaultQueryTimeout = 30 * time.Second
+
+// NewMigrator creates a new Migrator that works with the provider
+func NewMigrator(provider DbProvider) *Migrator {
+	m := &Migrator{
+		migrations:       make(map[string]mig),
+		provider:         provider,
+		lockTimeout:      DefaultLockTimeout,
+		queryTimeout:     DefaultQueryTimeout,
+		outOfOrderPolicy: DefaultOutOfOrderPolicy,
+		hostname:         currentHostname(),
+		osUser:           currentOSUser(),
+		logger:           nopLogger{},
+		metrics:          nopMetrics{},
+		tracer:           nopTracer{},
+	}
+
+	m.getApplied = m.defaultApplied
+	m.markApplied = m.defaultMarkApplied
+	m.markDirty = m.defaultMarkDirty
+	m.down = m.defaultDown
+
+	return m
+}
+
+// queryContext returns a context for a single database provider call
+func (m *Migrator) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, m.queryTimeout)
+}
+
+// createMigrationsTable makes sure that the provider has a table for migration history
+func (m *Migrator) createMigrationsTable(ctx context.Context, provider DbProvider) error {
+	m.logger.Debug("preparing migration history table")
+	err := m.providerCall(ctx, "CreateMigrationsTable", provider.CreateMigrationsTable)
+	if err != nil {
+		m.logger.Error("can't prepare migration history table", "error", err)
+	}
+
+	return err
+}
+
+func (m *Migrator) defaultApplied(ctx context.Context, provider DbProvider) ([]HistoryRecord, error) {
+	err := m.createMigrationsTable(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []HistoryRecord
+	err = m.providerCall(ctx, "GetApplied", func(ctx context.Context) error {
+		records, err = provider.GetApplied(ctx)
+		return err
+	})
+
+	return records, err
+}
+
+func (m *Migrator) defaultMarkApplied(ctx context.Context, provider DbProvider, record HistoryRecord) error {
+	err := m.createMigrationsTable(ctx, provider)
+	if err != nil {
+		return err
+	}
+
+	return m.providerCall(ctx, "MarkApplied", func(ctx context.Context) error {
+		return provider.MarkApplied(ctx, record)
+	})
+}
+
+func (m *Migrator) defaultMarkDirty(ctx context.Context, provider DbProvider, record HistoryRecord) error {
+	record.Dirty = true
+
+	return m.defaultMarkApplied(ctx, provider, record)
+}
+
+// historyRecord returns a history row of the migration run right now in the direction
+func (m *Migrator) historyRecord(name string, direction Direction) HistoryRecord {
+	return HistoryRecord{
+		Name:       name,
+		AppliedAt:  time.Now().UTC(),
+		Checksum:   m.migrations[name].checksum,
+		Hostname:   m.hostname,
+		OSUser:     m.osUser,
+		AppVersion: m.appVersion,
+		Direction:  string(direction),
+	}
+}
+
+// run runs f and marks the migration as dirty with the error if f fails
+func (m *Migrator) run(ctx context.Context, provider DbProvider, record *HistoryRecord, f func() error) error {
+	err := m.markDirty(ctx, provider, *record)
+	if err != nil {
+		return err
+	}
+
+	// UTC time has no monotonic clock reading, so the duration is measured separately
+	started := time.Now()
+	err = f()
+	if record.Direction == string(DirectionUp) {
+		// a migration being reverted keeps the duration of applying
+		record.Duration = time.Since(started)
+	}
+
+	if err != nil {
+		record.Error = err.Error()
+
+		// the history may be unavailable as well, so the error of the migration is more important
+		_ = m.markDirty(ctx, provider, *record)
+
+		return err
+	}
+
+	return nil
+}
+
+// recordNames returns names of migrations from history records
+func recordNames(records []HistoryRecord) []string {
+	names := make([]string, 0, len(records))
+	for _, record := range records {
+		names = append(names, record.Name)
+	}
+
+	return names
+}
+
+func (m *Migrator) defaultDown(ctx context.Context, provider DbProvider, records []HistoryRecord) ([]string, error) {
+	err := m.createMigrationsTable(ctx, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	downed := make([]string, 0, len(records))
+	for _, record := range records {
+		name := record.Name
+		err = ctx.Err()
+		if err != nil {
+			return downed, err
+		}
+
+		mig, ok := m.migrations[name]
+		if !ok {
+			return downed, fmt.Errorf("can't find migration '%s'", name)
+		}
+
+		err = m.migrate(ctx, name, DirectionDown, func() error {
+			if mig.transactional() {
+				return m.revertTx(ctx, provider, mig, record)
+			}
+
+			return m.revert(ctx, provider, mig, record)
+		})
+
+		if err != nil {
+			return downed, err
+		}
+
+		downed = append(downed, name)
+	}
+
+	return downed, nil
+}
+
+// revert downs migration and deletes it from the history.
+// The migration is marked as dirty until it is deleted, so a failed down leaves a dirty marker.
+// The marker is the applied record of the migration, so the history keeps who applied it and its checksum
+func (m *Migrator) revert(ctx context.Context, provider DbProvider, mig mig, record HistoryRecord) error {
+	record.Direction = string(DirectionDown)
+	record.Error = ""
+	err := m.run(ctx, provider, &record, func() error {
+		return m.traceMigration(ctx, mig.name, DirectionDown, func(ctx context.Context) error {
+			return mig.down(ctx, provider.GetDb())
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return m.providerCall(ctx, "DeleteApplied", func(ctx context.Context) error {
+		return provider.DeleteApplied(ctx, mig.name)
+	})
+}
+
+// revertTx downs migration and deletes it from the history in a single transaction.
+// If the database commits DDL implicitly, the applied record is the dirty marker like in revert
+func (m *Migrator) revertTx(ctx context.Context, provider DbProvider, mig mig, record HistoryRecord) error {
+	txProvider, err := asTxProvider(provider)
+	if err != nil {
+		return err
+	}
+
+	revert := func() error {
+		return inTx(ctx, provider.GetDb(), func(tx *sql.Tx) error {
+			err := m.traceMigration(ctx, mig.name, DirectionDown, func(ctx context.Context) error {
+				return mig.downTx(ctx, tx)
+			})
+			if err != nil {
+				return err
+			}
+
+			return m.providerCall(ctx, "DeleteAppliedTx", func(ctx context.Context) error {
+				return txProvider.DeleteAppliedTx(ctx, tx, mig.name)
+			})
+		})
+	}
+
+	if !commitsDDL(provider) {
+		return revert()
+	}
+
+	record.Direction = string(DirectionDown)
+	record.Error = ""
+
+	return m.run(ctx, provider, &record, revert)
+}
+
+// applyTx ups migration and marks it as applied in a single transaction.
+// If the database commits DDL implicitly, the migration is marked as dirty before the transaction like in apply
+func (m *Migrator) applyTx(ctx context.Context, provider DbProvider, mig mig, batch int64) error {
+	txProvider, err := asTxProvider(provider)
+	if err != nil {
+		return err
+	}
+
+	err = m.createMigrationsTable(ctx, provider)
+	if err != nil {
+		return err
+	}
+
+	record := m.historyRecord(mig.name, DirectionUp)
+	record.Batch = batch
+	apply := func() error {
+		started := time.Now()
+		return inTx(ctx, provider.GetDb(), func(tx *sql.Tx) error {
+			err := m.traceMigration(ctx, mig.name, DirectionUp, func(ctx context.Context) error {
+				return mig.upTx(ctx, tx)
+			})
+			if err != nil {
+				return err
+			}
+
+			record.Duration = time.Since(started)
+
+			// the row overwrites the dirty marker if there is one
+			return m.providerCall(ctx, "MarkAppliedTx", func(ctx context.Context) error {
+				return txProvider.MarkAppliedTx(ctx, tx, record)
+			})
+		})
+	}
+
+	if !commitsDDL(provider) {
+		return apply()
+	}
+
+	return m.run(ctx, provider, &record, apply)
+}
+
+// commitsDDL tells whether the database of the provider commits DDL statements implicitly,
+// so a transactional migration needs a dirty marker written outside of its transaction
+func commitsDDL(provider DbProvider) bool {
+	committer, ok := provider.(DDLCommitter)
+	return ok && committer.CommitsDDL()
+}
+
+func asTxProvider(provider DbProvider) (TxProvider, error) {
+	txProvider, ok := provider.(TxProvider)
+	if !ok {
+		return nil, errors.New("database provider doesn't support transactional migrations")
+	}
+
+	return txProvider, nil
+}
+
+// inTx runs f inside a transaction. The transaction is committed if f succeeds and rolled back otherwise
+func inTx(ctx context.Context, db *sql.DB, f func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// withContext turns a migration function into a function that accepts context
+func withContext(f func(db *sql.DB) error) func(ctx context.Context, db *sql.DB) error {
+	if f == nil {
+		return nil
+	}
+
+	return func(_ context.Context, db *sql.DB) error {
+		return f(db)
+	}
+}
+
+// txWithContext turns a transactional migration function into a function that accepts context
+func txWithContext(f func(tx *sql.Tx) error) func(ctx context.Context, tx *sql.Tx) error {
+	if f == nil {
+		return nil
+	}
+
+	return func(_ context.Context, tx *sql.Tx) error {
+		return f(tx)
+	}
+}
+
+// Add adds mig to the migrator's queue
+func (m *Migrator) Add(name string, up UpFunc, down DownFunc, opts ...MigrationOption) {
+	m.AddContext(name, withContext(up), withContext(down), opts...)
+}
+
+// AddContext adds mig which functions accept context to the migrator's queue
+func (m *Migrator) AddContext(name string, up UpContextFunc, down DownContextFunc, opts ...MigrationOption) {
+	m.add(mig{
+		name: name,
+		up:   up,
+		down: down,
+	}, opts)
+}
+
+// AddTx adds mig that should be run inside a transaction to the migrator's queue.
+// The migration and its history row are committed together, so the provider has to implement TxProvider
+func (m *Migrator) AddTx(name string, up TxUpFunc, down TxDownFunc, opts ...MigrationOption) {
+	m.AddTxContext(name, txWithContext(up), txWithContext(down), opts...)
+}
+
+// AddTxContext adds mig that should be run inside a transaction and which functions accept context
+// to the migrator's queue
+func (m *Migrator) AddTxContext(name string, up TxUpContextFunc, down TxDownContextFunc, opts ...MigrationOption) {
+	m.add(mig{
+		name:   name,
+		upTx:   up,
+		downTx: down,
+	}, opts)
+}
+
+// add adds mig to the migrator's queue. Problems of mig are reported by Validate,
+// and a duplicate doesn't replace the migration added first
+func (m *Migrator) add(mig mig, opts []MigrationOption) {
+	for _, opt := range opts {
+		opt(&mig)
+	}
+
+	if problem := m.registrationProblem(mig); len(problem) > 0 {
+		m.problems = append(m.problems, problem)
+		if _, ok := m.migrations[mig.name]; ok {
+			return
+		}
+	}
+
+	m.migrations[mig.name] = mig
+}
+
+// SetDatabaseProvider sets a DbProvider that the migrator should use for applying migrations
+func (m *Migrator) SetDatabaseProvider(provider DbProvider) {
+	m.provider = provider
+}
+
+// SetLockTimeout sets how long Apply and Down should wait for the migration lock
+// if the database provider implements Locker.
+// Pass 0 to wait as long as the context passed to the migrator allows
+func (m *Migrator) SetLockTimeout(timeout time.Duration) {
+	m.lockTimeout = timeout
+}
+
+// SetAppVersion sets version of the application that is recorded to the history with every migration
+func (m *Migrator) SetAppVersion(version string) {
+	m.appVersion = version
+}
+
+// SetQueryTimeout sets timeout of a single database provider call.
+// Pass 0 to rely only on the context passed to the migrator
+func (m *Migrator) SetQueryTimeout(timeout time.Duration) {
+	m.queryTimeout = timeout
+}
+
+// lockContext returns a context for acquiring the migration lock
+func (m *Migrator) lockContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.lockTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, m.lockTimeout)
+}
+
+// withLock runs f holding the migration lock if the database provider implements Locker
+func (m *Migrator) withLock(ctx context.Context, f func() error) error {
+	locker, ok := m.provider.(Locker)
+	if !ok {
+		return f()
+	}
+
+	lockCtx, cancel := m.lockContext(ctx)
+	defer cancel()
+
+	m.logger.Debug("waiting for migration lock", "timeout", m.lockTimeout)
+	err := m.traceProviderCall(lockCtx, "Lock", locker.Lock)
+	if err != nil {
+		return fmt.Errorf("can't acquire migration lock: %w", err)
+	}
+
+	m.logger.Debug("migration lock is acquired")
+
+	err = f()
+
+	// the lock should be released even if ctx is already cancelled
+	unlockErr := m.providerCall(context.Background(), "Unlock", locker.Unlock)
+	if err != nil {
+		return err
+	}
+
+	if unlockErr != nil {
+		return fmt.Errorf("can't release migration lock: %w", unlockErr)
+	}
+
+	return nil
+}
+
+// NewNames returns names of new migrations
+func (m *Migrator) NewNames() ([]string, error) {
+	return m.NewNamesContext(context.Background())
+}
+
+// NewNamesContext returns names of new migrations
+func (m *Migrator) NewNamesContext(ctx context.Context) ([]string, error) {
+	records, err := m.getApplied(ctx, m.provider)
+	if err != nil {
+		return nil, err
+	}
+
+	m.observePending(records)
+
+	return m.newNames(records), nil
+}
+
+// newNames returns names of registered migrations that aren't in records in the order of applying
+func (m *Migrator) newNames(records []HistoryRecord) []string {
+	applied := map[string]bool{}
+	for _, record := range records {
+		applied[record.Name] = true
+	}
+
+	result := make([]string, 0)
+	for name := range m.migrations {
+		if !applied[name] {
+			result = append(result, name)
+		}
+	}
+
+	return m.sortByDependencies(result)
+}
+
+// cleanRecords returns history records or ErrDirty if there is a dirty migration
+func (m *Migrator) cleanRecords(ctx context.Context) ([]HistoryRecord, error) {
+	records, err := m.getApplied(ctx, m.provider)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		if record.Dirty {
+			return nil, fmt.Errorf("%w: migration '%s' failed in the middle, fix the database and force its state", ErrDirty, record.Name)
+		}
+	}
+
+	return records, nil
+}
+
+// Apply applies new migrations
+func (m *Migrator) Apply() ([]string, error) {
+	return m.ApplyContext(context.Background())
+}
+
+// ApplyContext applies new migrations.
+// ctx is passed to migration functions, so the run stops as soon as ctx is done
+func (m *Migrator) ApplyContext(ctx context.Context) ([]string, error) {
+	return m.runMigrations(ctx, DirectionUp, func() ([]string, []HistoryRecord, error) {
+		records, err := m.cleanRecords(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		names, err := m.pendingNames(records)
+		return names, records, err
+	})
+}
+
+// applyNames applies the named migrations and records them to the history as the batch
+func (m *Migrator) applyNames(ctx context.Context, names []string, batch int64) ([]string, error) {
+	applied := make([]string, 0, len(names))
+	for _, name := range names {
+		err := ctx.Err()
+		if err != nil {
+			return applied, err
+		}
+
+		mig := m.migrations[name]
+		err = m.migrate(ctx, name, DirectionUp, func() error {
+			if mig.transactional() {
+				return m.applyTx(ctx, m.provider, mig, batch)
+			}
+
+			return m.apply(ctx, mig, batch)
+		})
+
+		if err != nil {
+			return applied, err
+		}
+
+		applied = append(applied, name)
+	}
+
+	return applied, nil
+}
+
+// apply ups migration and marks it as applied.
+// The migration is marked as dirty before it is run, so a failed up leaves a dirty marker
+func (m *Migrator) apply(ctx context.Context, mig mig, batch int64) error {
+	record := m.historyRecord(mig.name, DirectionUp)
+	record.Batch = batch
+	err := m.run(ctx, m.provider, &record, func() error {
+		return m.traceMigration(ctx, mig.name, DirectionUp, func(ctx context.Context) error {
+			return mig.up(ctx, m.provider.GetDb())
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return m.markApplied(ctx, m.provider, record)
+}
+
+// ApplyTo applies new migrations up to and including the named one
+func (m *Migrator) ApplyTo(name string) ([]string, error) {
+	return m.ApplyToContext(context.Background(), name)
+}
+
+// ApplyToContext applies new migrations up to and including the named one
+// and stops as soon as ctx is done
+func (m *Migrator) ApplyToContext(ctx context.Context, name string) ([]string, error) {
+	return m.runMigrations(ctx, DirectionUp, func() ([]string, []HistoryRecord, error) {
+		return m.namesToApplyTo(ctx, name)
+	})
+}
+
+// namesToApplyTo returns names of new migrations up to and including the named one
+// and history records they were chosen from
+func (m *Migrator) namesToApplyTo(ctx context.Context, name string) ([]string, []HistoryRecord, error) {
+	if _, ok := m.migrations[name]; !ok {
+		return nil, nil, fmt.Errorf("can't find migration '%s'", name)
+	}
+
+	records, err := m.cleanRecords(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newNames := m.newNames(records)
+	for i, n := range newNames {
+		if n == name {
+			return newNames[:i+1], records, m.checkOrder(records, newNames[:i+1])
+		}
+	}
+
+	return []string{}, records, nil
+}
+
+// History returns chronological history of applied migrations
+func (m *Migrator) History() ([]HistoryRecord, error) {
+	return m.HistoryContext(context.Background())
+}
+
+// HistoryContext returns chronological history of applied migrations
+func (m *Migrator) HistoryContext(ctx context.Context) ([]HistoryRecord, error) {
+	return m.getApplied(ctx, m.provider)
+}
+
+// Down reverts particular number of migrations
+// Pass 0 as a number to revert all migrations
+func (m *Migrator) Down(number int) ([]string, error) {
+	return m.DownContext(context.Background(), number)
+}
+
+// DownContext reverts particular number of migrations
+// Pass 0 as a number to revert all migrations.
+// ctx is passed to migration functions, so the run stops as soon as ctx is done
+func (m *Migrator) DownContext(ctx context.Context, number int) ([]string, error) {
+	return m.runMigrations(ctx, DirectionDown, func() ([]string, []HistoryRecord, error) {
+		return m.namesToDown(ctx, number)
+	})
+}
+
+// namesToDown returns names of particular number of the latest applied migrations
+// and history records they were chosen from
+func (m *Migrator) namesToDown(ctx context.Context, number int) ([]string, []HistoryRecord, error) {
+	if number < 0 {
+		return nil, nil, fmt.Errorf("number of migrations should not be negative, got %d", number)
+	}
+
+	records, err := m.cleanRecords(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	appliedNames := recordNames(records)
+
+	endIndex := number
+	if number >= len(appliedNames) || number == 0 {
+		endIndex = len(appliedNames)
+	}
+
+	return appliedNames[:endIndex], records, m.checkDown(records, appliedNames[:endIndex])
+}
+
+// checkDown returns an error if a migration of names isn't registered or can't be reverted
+// or applied migrations that aren't going to be reverted depend on it
+func (m *Migrator) checkDown(records []HistoryRecord, names []string) error {
+	for _, name := range names {
+		mig, ok := m.migrations[name]
+		if !ok {
+			return fmt.Errorf("can't revert migration '%s': it isn't registered", name)
+		}
+
+		if !mig.reversible() {
+			return fmt.Errorf("%w: can't revert migration '%s'", ErrIrreversible, name)
+		}
+	}
+
+	return m.checkDependents(records, names)
+}
+
+// downNames downs the named migrations. records are history records the names were chosen from
+func (m *Migrator) downNames(ctx context.Context, names []string, records []HistoryRecord) ([]string, error) {
+	if len(names) == 0 {
+		return []string{}, nil
+	}
+
+	applied := make(map[string]HistoryRecord, len(records))
+	for _, record := range records {
+		applied[record.Name] = record
+	}
+
+	toDown := make([]HistoryRecord, 0, len(names))
+	for _, name := range names {
+		toDown = append(toDown, applied[name])
+	}
+
+	return m.down(ctx, m.provider, toDown)
+}
+
+// DownTo reverts all migrations applied after the named one
+func (m *Migrator) DownTo(name string) ([]string, error) {
+	return m.DownToContext(context.Background(), name)
+}
+
+// DownToContext reverts all migrations applied after the named one
+// and stops as soon as ctx is done
+func (m *Migrator) DownToContext(ctx context.Context, name string) ([]string, error) {
+	return m.runMigrations(ctx, DirectionDown, func() ([]string, []HistoryRecord, error) {
+		return m.namesToDownTo(ctx, name)
+	})
+}
+
+// namesToDownTo returns names of migrations applied after the named one
+// and history records they were chosen from
+func (m *Migrator) namesToDownTo(ctx context.Context, name string) ([]string, []HistoryRecord, error) {
+	records, err := m.cleanRecords(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	appliedNames := recordNames(records)
+
+	// applied names are sorted from the latest to the earliest one
+	for i, n := range appliedNames {
+		if n == name {
+			return appliedNames[:i], records, m.checkDown(records, appliedNames[:i])
+		}
+	}
+
+	return nil, nil, fmt.Errorf("migration '%s' isn't applied", name)
+}
+
+// currentHostname returns name of the host or empty string if it is unknown
+func currentHostname() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+
+	return hostname
+}
+
+// currentOSUser returns name of the OS user or empty string if it is unknown
+func currentOSUser() string {
+	u, err := user.Current()
+	if err == nil {
+		return u.Username
+	}
+
+	return os.Getenv("USER")
+}
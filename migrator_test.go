@@ -0,0 +1,649 @@
+package mymigrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang/mock/gomock"
+	"github.com/iamsalnikov/mymigrate/migrationtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrator_IsolatedRegistries(t *testing.T) {
+	first := NewMigrator(nil)
+	second := NewMigrator(nil)
+
+	noop := func(db *sql.DB) error { return nil }
+	first.Add("mig_001", noop, noop)
+	second.Add("mig_002", noop, noop)
+
+	for _, m := range []*Migrator{first, second} {
+		m.getApplied = func(ctx context.Context, provider DbProvider) ([]HistoryRecord, error) {
+			return historyRecords(), nil
+		}
+	}
+
+	firstNames, err := first.NewNames()
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"mig_001"}, firstNames)
+
+	secondNames, err := second.NewNames()
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"mig_002"}, secondNames)
+}
+
+func TestMigrator_ApplyTx(t *testing.T) {
+	upErr := errors.New("up error")
+
+	testCases := map[string]struct {
+		upErr       error
+		markErr     error
+		expCommit   bool
+		expErr      error
+		expApplied  []string
+		expMarkCall bool
+	}{
+		"migration and history row are committed together": {
+			expCommit:   true,
+			expApplied:  []string{"mig_001"},
+			expMarkCall: true,
+		},
+		"failed migration is rolled back": {
+			upErr:      upErr,
+			expErr:     upErr,
+			expApplied: []string{},
+		},
+		"failed history row rolls back migration": {
+			markErr:     upErr,
+			expErr:      upErr,
+			expApplied:  []string{},
+			expMarkCall: true,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			ctrl := gomock.NewController(t)
+			provider := migrationtest.NewMockTxProvider(ctrl)
+			provider.EXPECT().GetDb().Return(db).AnyTimes()
+			provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+			provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords(), nil)
+			if tc.expMarkCall {
+				provider.EXPECT().MarkAppliedTx(gomock.Any(), gomock.Any(), appliedRecord("mig_001")).Return(tc.markErr)
+			}
+
+			mock.ExpectBegin()
+			if tc.expCommit {
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			m := NewMigrator(provider)
+			m.AddTx(
+				"mig_001",
+				func(tx *sql.Tx) error { return tc.upErr },
+				func(tx *sql.Tx) error { return nil },
+			)
+
+			applied, err := m.Apply()
+			assert.EqualValues(t, tc.expErr, err)
+			assert.EqualValues(t, tc.expApplied, applied)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMigrator_DownTx(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	ctrl := gomock.NewController(t)
+	provider := migrationtest.NewMockTxProvider(ctrl)
+	provider.EXPECT().GetDb().Return(db).AnyTimes()
+	provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords("mig_001"), nil)
+	provider.EXPECT().DeleteAppliedTx(gomock.Any(), gomock.Any(), "mig_001").Return(nil)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	m := NewMigrator(provider)
+	m.AddTx(
+		"mig_001",
+		func(tx *sql.Tx) error { return nil },
+		func(tx *sql.Tx) error { return nil },
+	)
+
+	downed, err := m.Down(1)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"mig_001"}, downed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// ddlCommittingProvider is a transactional provider of a database that commits DDL statements implicitly
+type ddlCommittingProvider struct {
+	*migrationtest.MockTxProvider
+}
+
+func (p ddlCommittingProvider) CommitsDDL() bool {
+	return true
+}
+
+func TestMigrator_TxOnDDLCommittingDatabase(t *testing.T) {
+	migErr := errors.New("migration error")
+
+	testCases := map[string]struct {
+		history []HistoryRecord
+		run     func(m *Migrator) ([]string, error)
+		upErr   error
+		downErr error
+
+		expect    func(provider *migrationtest.MockTxProvider)
+		expCommit bool
+		expErr    error
+		expNames  []string
+	}{
+		"applied migration overwrites dirty marker": {
+			history: historyRecords(),
+			run:     (*Migrator).Apply,
+			expect: func(provider *migrationtest.MockTxProvider) {
+				gomock.InOrder(
+					provider.EXPECT().MarkApplied(gomock.Any(), dirtyRecord("mig_001")).Return(nil),
+					provider.EXPECT().MarkAppliedTx(gomock.Any(), gomock.Any(), appliedRecord("mig_001")).Return(nil),
+				)
+			},
+			expCommit: true,
+			expNames:  []string{"mig_001"},
+		},
+		"failed apply leaves dirty marker": {
+			history: historyRecords(),
+			run:     (*Migrator).Apply,
+			upErr:   migErr,
+			expect: func(provider *migrationtest.MockTxProvider) {
+				gomock.InOrder(
+					provider.EXPECT().MarkApplied(gomock.Any(), dirtyRecord("mig_001")).Return(nil),
+					provider.EXPECT().MarkApplied(gomock.Any(), dirtyRecord("mig_001")).DoAndReturn(
+						func(ctx context.Context, record HistoryRecord) error {
+							assert.Equal(t, "migration error", record.Error)
+							return nil
+						},
+					),
+				)
+			},
+			expErr:   migErr,
+			expNames: []string{},
+		},
+		"failed down leaves dirty marker": {
+			history: historyRecords("mig_001"),
+			run: func(m *Migrator) ([]string, error) {
+				return m.Down(1)
+			},
+			downErr: migErr,
+			expect: func(provider *migrationtest.MockTxProvider) {
+				gomock.InOrder(
+					provider.EXPECT().MarkApplied(gomock.Any(), dirtyRecord("mig_001")).Return(nil),
+					provider.EXPECT().MarkApplied(gomock.Any(), dirtyRecord("mig_001")).DoAndReturn(
+						func(ctx context.Context, record HistoryRecord) error {
+							assert.Equal(t, string(DirectionDown), record.Direction)
+							assert.Equal(t, "migration error", record.Error)
+							return nil
+						},
+					),
+				)
+			},
+			expErr:   migErr,
+			expNames: []string{},
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			ctrl := gomock.NewController(t)
+			txProvider := migrationtest.NewMockTxProvider(ctrl)
+			txProvider.EXPECT().GetDb().Return(db).AnyTimes()
+			txProvider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+			txProvider.EXPECT().GetApplied(gomock.Any()).Return(tc.history, nil)
+			tc.expect(txProvider)
+
+			mock.ExpectBegin()
+			if tc.expCommit {
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			m := NewMigrator(ddlCommittingProvider{MockTxProvider: txProvider})
+			m.AddTx(
+				"mig_001",
+				func(tx *sql.Tx) error { return tc.upErr },
+				func(tx *sql.Tx) error { return tc.downErr },
+			)
+
+			names, err := tc.run(m)
+			assert.Equal(t, tc.expErr, err)
+			assert.Equal(t, tc.expNames, names)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMigrator_ApplyTxWithoutTxProvider(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	provider := migrationtest.NewMockDbProvider(ctrl)
+	provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords(), nil)
+
+	m := NewMigrator(provider)
+	m.AddTx(
+		"mig_001",
+		func(tx *sql.Tx) error { return nil },
+		func(tx *sql.Tx) error { return nil },
+	)
+
+	applied, err := m.Apply()
+	assert.Error(t, err)
+	assert.EqualValues(t, []string{}, applied)
+}
+
+type lockingProvider struct {
+	*migrationtest.MockDbProvider
+	*migrationtest.MockLocker
+}
+
+func TestMigrator_ApplyHoldsLock(t *testing.T) {
+	lockErr := errors.New("lock error")
+
+	testCases := map[string]struct {
+		lockErr    error
+		unlockErr  error
+		expErr     string
+		expApplied []string
+	}{
+		"lock is held for the whole run": {
+			expApplied: []string{"mig_001"},
+		},
+		"lock isn't acquired": {
+			lockErr: lockErr,
+			expErr:  "can't acquire migration lock: lock error",
+		},
+		"lock isn't released": {
+			unlockErr:  lockErr,
+			expErr:     "can't release migration lock: lock error",
+			expApplied: []string{"mig_001"},
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			provider := lockingProvider{
+				MockDbProvider: migrationtest.NewMockDbProvider(ctrl),
+				MockLocker:     migrationtest.NewMockLocker(ctrl),
+			}
+			provider.MockDbProvider.EXPECT().GetDb().AnyTimes()
+			provider.MockDbProvider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+
+			lock := provider.MockLocker.EXPECT().Lock(gomock.Any()).Return(tc.lockErr)
+			if tc.lockErr == nil {
+				gomock.InOrder(
+					lock,
+					provider.MockDbProvider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords(), nil),
+					provider.MockDbProvider.EXPECT().MarkApplied(gomock.Any(), dirtyRecord("mig_001")).Return(nil),
+					provider.MockDbProvider.EXPECT().MarkApplied(gomock.Any(), appliedRecord("mig_001")).Return(nil),
+					provider.MockLocker.EXPECT().Unlock(gomock.Any()).Return(tc.unlockErr),
+				)
+			}
+
+			m := NewMigrator(provider)
+			m.Add(
+				"mig_001",
+				func(db *sql.DB) error { return nil },
+				func(db *sql.DB) error { return nil },
+			)
+
+			applied, err := m.Apply()
+			if tc.expErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expErr)
+			}
+
+			assert.EqualValues(t, tc.expApplied, applied)
+		})
+	}
+}
+
+func TestMigrator_LockWithoutTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	provider := lockingProvider{
+		MockDbProvider: migrationtest.NewMockDbProvider(ctrl),
+		MockLocker:     migrationtest.NewMockLocker(ctrl),
+	}
+	provider.MockDbProvider.EXPECT().GetDb().AnyTimes()
+	provider.MockDbProvider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	provider.MockDbProvider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords(), nil)
+	provider.MockDbProvider.EXPECT().MarkApplied(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	provider.MockLocker.EXPECT().Lock(gomock.Any()).DoAndReturn(func(ctx context.Context) error {
+		_, hasDeadline := ctx.Deadline()
+		assert.False(t, hasDeadline)
+		return ctx.Err()
+	})
+	provider.MockLocker.EXPECT().Unlock(gomock.Any()).Return(nil)
+
+	m := NewMigrator(provider)
+	m.SetLockTimeout(0)
+	m.Add("mig_001", func(db *sql.DB) error { return nil }, nil)
+
+	applied, err := m.Apply()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mig_001"}, applied)
+}
+
+func TestMigrator_ApplyContext(t *testing.T) {
+	type ctxKey struct{}
+
+	provider := migrationtest.NewMockDbProvider(gomock.NewController(t))
+	provider.EXPECT().GetDb().AnyTimes()
+
+	m := NewMigrator(provider)
+	m.getApplied = func(ctx context.Context, provider DbProvider) ([]HistoryRecord, error) {
+		return historyRecords(), nil
+	}
+	m.markApplied = func(ctx context.Context, provider DbProvider, record HistoryRecord) error {
+		return nil
+	}
+	m.markDirty = func(ctx context.Context, provider DbProvider, record HistoryRecord) error {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "deploy"))
+	defer cancel()
+
+	ran := make([]string, 0)
+	m.AddContext(
+		"mig_001",
+		func(ctx context.Context, db *sql.DB) error {
+			assert.Equal(t, "deploy", ctx.Value(ctxKey{}))
+			ran = append(ran, "mig_001")
+			// SIGTERM during the first migration
+			cancel()
+			return nil
+		},
+		nil,
+	)
+	m.AddContext(
+		"mig_002",
+		func(ctx context.Context, db *sql.DB) error {
+			ran = append(ran, "mig_002")
+			return nil
+		},
+		nil,
+	)
+
+	applied, err := m.ApplyContext(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.EqualValues(t, []string{"mig_001"}, applied)
+	assert.EqualValues(t, []string{"mig_001"}, ran)
+}
+
+func TestMigrator_ApplyTo(t *testing.T) {
+	testCases := map[string]struct {
+		registered []string
+		applied    []string
+		target     string
+		expApplied []string
+		expErr     string
+	}{
+		"applies up to and including target": {
+			registered: []string{"mig_001", "mig_002", "mig_003"},
+			applied:    []string{},
+			target:     "mig_002",
+			expApplied: []string{"mig_001", "mig_002"},
+		},
+		"skips applied migrations": {
+			registered: []string{"mig_001", "mig_002", "mig_003"},
+			applied:    []string{"mig_001"},
+			target:     "mig_003",
+			expApplied: []string{"mig_002", "mig_003"},
+		},
+		"target is already applied": {
+			registered: []string{"mig_001", "mig_002"},
+			applied:    []string{"mig_002", "mig_001"},
+			target:     "mig_001",
+			expApplied: []string{},
+		},
+		"unknown target": {
+			registered: []string{"mig_001"},
+			applied:    []string{},
+			target:     "mig_404",
+			expErr:     "can't find migration 'mig_404'",
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			provider := migrationtest.NewMockDbProvider(gomock.NewController(t))
+			provider.EXPECT().GetDb().AnyTimes()
+
+			m := NewMigrator(provider)
+			m.getApplied = func(ctx context.Context, provider DbProvider) ([]HistoryRecord, error) {
+				return historyRecords(tc.applied...), nil
+			}
+			m.markApplied = func(ctx context.Context, provider DbProvider, record HistoryRecord) error {
+				return nil
+			}
+			m.markDirty = func(ctx context.Context, provider DbProvider, record HistoryRecord) error {
+				return nil
+			}
+
+			for _, name := range tc.registered {
+				m.Add(name, func(db *sql.DB) error { return nil }, nil)
+			}
+
+			applied, err := m.ApplyTo(tc.target)
+			if tc.expErr != "" {
+				assert.EqualError(t, err, tc.expErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.EqualValues(t, tc.expApplied, applied)
+		})
+	}
+}
+
+func TestMigrator_DownTo(t *testing.T) {
+	testCases := map[string]struct {
+		applied       []string
+		target        string
+		expDownNames  []string
+		expDownCalled bool
+		expErr        string
+	}{
+		"reverts migrations applied after target": {
+			applied:       []string{"mig_003", "mig_002", "mig_001"},
+			target:        "mig_001",
+			expDownNames:  []string{"mig_003", "mig_002"},
+			expDownCalled: true,
+		},
+		"target is the latest applied": {
+			applied:      []string{"mig_003", "mig_002", "mig_001"},
+			target:       "mig_003",
+			expDownNames: []string{},
+		},
+		"target isn't applied": {
+			applied: []string{"mig_001"},
+			target:  "mig_002",
+			expErr:  "migration 'mig_002' isn't applied",
+		},
+		"unregistered migration in range": {
+			applied: []string{"mig_003", "mig_000", "mig_002", "mig_001"},
+			target:  "mig_001",
+			expErr:  "can't revert migration 'mig_000': it isn't registered",
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			m := NewMigrator(nil)
+			for _, name := range []string{"mig_001", "mig_002", "mig_003"} {
+				m.Add(name, func(db *sql.DB) error { return nil }, func(db *sql.DB) error { return nil })
+			}
+			m.getApplied = func(ctx context.Context, provider DbProvider) ([]HistoryRecord, error) {
+				return historyRecords(tc.applied...), nil
+			}
+
+			isDownCalled := false
+			m.down = func(ctx context.Context, provider DbProvider, records []HistoryRecord) ([]string, error) {
+				assert.EqualValues(t, tc.expDownNames, recordNames(records))
+				isDownCalled = true
+				return recordNames(records), nil
+			}
+
+			downed, err := m.DownTo(tc.target)
+			assert.Equal(t, tc.expDownCalled, isDownCalled)
+			if tc.expErr != "" {
+				assert.EqualError(t, err, tc.expErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.EqualValues(t, tc.expDownNames, downed)
+		})
+	}
+}
+
+func TestMigrator_FailedApplyLeavesDirtyMarker(t *testing.T) {
+	upErr := errors.New("up error")
+
+	ctrl := gomock.NewController(t)
+	provider := migrationtest.NewMockDbProvider(ctrl)
+	provider.EXPECT().GetDb().AnyTimes()
+	provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	gomock.InOrder(
+		provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords(), nil),
+		provider.EXPECT().MarkApplied(gomock.Any(), dirtyRecord("mig_001")).Return(nil),
+		provider.EXPECT().MarkApplied(gomock.Any(), dirtyRecord("mig_001")).DoAndReturn(
+			func(ctx context.Context, record HistoryRecord) error {
+				assert.Equal(t, "up error", record.Error)
+				assert.Equal(t, string(DirectionUp), record.Direction)
+				assert.Equal(t, "1.2.3", record.AppVersion)
+				return nil
+			},
+		),
+	)
+
+	m := NewMigrator(provider)
+	m.SetAppVersion("1.2.3")
+	m.Add(
+		"mig_001",
+		func(db *sql.DB) error { return upErr },
+		func(db *sql.DB) error { return nil },
+	)
+
+	applied, err := m.Apply()
+	assert.Equal(t, upErr, err)
+	assert.EqualValues(t, []string{}, applied)
+}
+
+func TestMigrator_FailedDownKeepsAppliedRecord(t *testing.T) {
+	downErr := errors.New("down error")
+	appliedAt := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	applied := HistoryRecord{
+		Name:       "mig_001",
+		AppliedAt:  appliedAt,
+		Checksum:   "old",
+		Duration:   time.Second,
+		Hostname:   "deploy-host",
+		OSUser:     "deploy",
+		AppVersion: "v1",
+		Direction:  string(DirectionUp),
+		Batch:      5,
+	}
+
+	ctrl := gomock.NewController(t)
+	provider := migrationtest.NewMockDbProvider(ctrl)
+	provider.EXPECT().GetDb().AnyTimes()
+	provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	provider.EXPECT().GetApplied(gomock.Any()).Return([]HistoryRecord{applied}, nil).AnyTimes()
+
+	expectMarker := applied
+	expectMarker.Dirty = true
+	expectMarker.Direction = string(DirectionDown)
+	gomock.InOrder(
+		provider.EXPECT().MarkApplied(gomock.Any(), expectMarker).Return(nil),
+		provider.EXPECT().MarkApplied(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(ctx context.Context, record HistoryRecord) error {
+				expectMarker.Error = "down error"
+				assert.Equal(t, expectMarker, record)
+				return nil
+			},
+		),
+	)
+
+	m := NewMigrator(provider)
+	m.SetAppVersion("v2")
+	m.Add(
+		"mig_001",
+		func(db *sql.DB) error { return nil },
+		func(db *sql.DB) error { return downErr },
+		WithVersion("new"),
+	)
+
+	downed, err := m.Down(1)
+	assert.Equal(t, downErr, err)
+	assert.EqualValues(t, []string{}, downed)
+}
+
+func TestMigrator_RefusesToRunWhileDirty(t *testing.T) {
+	testCases := map[string]func(m *Migrator) ([]string, error){
+		"apply": func(m *Migrator) ([]string, error) {
+			return m.Apply()
+		},
+		"apply to": func(m *Migrator) ([]string, error) {
+			return m.ApplyTo("mig_002")
+		},
+		"down": func(m *Migrator) ([]string, error) {
+			return m.Down(1)
+		},
+		"down to": func(m *Migrator) ([]string, error) {
+			return m.DownTo("mig_001")
+		},
+	}
+
+	for tcName, run := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			m := NewMigrator(nil)
+			m.getApplied = func(ctx context.Context, provider DbProvider) ([]HistoryRecord, error) {
+				return []HistoryRecord{{Name: "mig_002", Dirty: true}, {Name: "mig_001"}}, nil
+			}
+			m.down = func(ctx context.Context, provider DbProvider, records []HistoryRecord) ([]string, error) {
+				t.Errorf("migrations %v shouldn't be downed", recordNames(records))
+				return recordNames(records), nil
+			}
+
+			m.Add("mig_001", func(db *sql.DB) error { return nil }, nil)
+			m.Add("mig_002", func(db *sql.DB) error {
+				t.Error("migration shouldn't be applied")
+				return nil
+			}, nil)
+
+			_, err := run(m)
+			assert.True(t, errors.Is(err, ErrDirty))
+		})
+	}
+}
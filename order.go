@@ -0,0 +1,76 @@
+package mymigrate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OutOfOrderPolicy tells what Apply does with new migrations that sort before the latest applied one.
+// It usually happens when a branch with an older migration is merged after a newer migration was applied
+type OutOfOrderPolicy string
+
+const (
+	// OutOfOrderFail means that Apply returns ErrOutOfOrder and applies nothing
+	OutOfOrderFail OutOfOrderPolicy = "fail"
+	// OutOfOrderWarn means that Apply logs a warning and applies the migrations
+	OutOfOrderWarn OutOfOrderPolicy = "warn"
+	// OutOfOrderAllow means that Apply silently applies the migrations
+	OutOfOrderAllow OutOfOrderPolicy = "allow"
+)
+
+// DefaultOutOfOrderPolicy - what Apply does with out of order migrations by default
+const DefaultOutOfOrderPolicy = OutOfOrderWarn
+
+// ErrOutOfOrder is returned by Apply if there are out of order migrations and the policy is OutOfOrderFail
+var ErrOutOfOrder = errors.New("migrations are out of order")
+
+// SetOutOfOrderPolicy sets what Apply does with new migrations that sort before the latest applied one
+func (m *Migrator) SetOutOfOrderPolicy(policy OutOfOrderPolicy) {
+	m.outOfOrderPolicy = policy
+}
+
+// latestAppliedName returns the greatest name of history records
+func latestAppliedName(records []HistoryRecord) string {
+	latest := ""
+	for _, record := range records {
+		if record.Name > latest {
+			latest = record.Name
+		}
+	}
+
+	return latest
+}
+
+// pendingNames returns names of new migrations and checks their order according to the policy
+func (m *Migrator) pendingNames(records []HistoryRecord) ([]string, error) {
+	names := m.newNames(records)
+
+	return names, m.checkOrder(records, names)
+}
+
+// checkOrder finds names that sort before the latest applied migration and acts according to the policy
+func (m *Migrator) checkOrder(records []HistoryRecord, names []string) error {
+	latest := latestAppliedName(records)
+	outOfOrder := make([]string, 0)
+	for _, name := range names {
+		if name < latest {
+			outOfOrder = append(outOfOrder, name)
+		}
+	}
+
+	if len(outOfOrder) == 0 {
+		return nil
+	}
+
+	switch m.outOfOrderPolicy {
+	case OutOfOrderFail:
+		return fmt.Errorf("%w: %s sort before the latest applied migration '%s'",
+			ErrOutOfOrder, strings.Join(outOfOrder, ", "), latest)
+	case OutOfOrderWarn:
+		m.logger.Warn("new migrations sort before the latest applied migration",
+			"migrations", strings.Join(outOfOrder, ", "), "latest_applied", latest)
+	}
+
+	return nil
+}
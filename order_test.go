@@ -0,0 +1,111 @@
+package mymigrate
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iamsalnikov/mymigrate/migrationtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrator_OutOfOrderPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		policy OutOfOrderPolicy
+		to     string
+
+		expectApplied  []string
+		expectErr      error
+		expectMessages []string
+	}{
+		"fail": {
+			policy:         OutOfOrderFail,
+			expectApplied:  nil,
+			expectErr:      ErrOutOfOrder,
+			expectMessages: []string{},
+		},
+		"warn": {
+			policy:        OutOfOrderWarn,
+			expectApplied: []string{"mig_001", "mig_002", "mig_004"},
+			expectMessages: []string{
+				"warn new migrations sort before the latest applied migration migrations=mig_001, mig_002 latest_applied=mig_003",
+			},
+		},
+		"allow": {
+			policy:         OutOfOrderAllow,
+			expectApplied:  []string{"mig_001", "mig_002", "mig_004"},
+			expectMessages: []string{},
+		},
+		"fail on apply to": {
+			policy:         OutOfOrderFail,
+			to:             "mig_001",
+			expectApplied:  nil,
+			expectErr:      ErrOutOfOrder,
+			expectMessages: []string{},
+		},
+		"warn on apply to": {
+			policy:        OutOfOrderWarn,
+			to:            "mig_001",
+			expectApplied: []string{"mig_001"},
+			expectMessages: []string{
+				"warn new migrations sort before the latest applied migration migrations=mig_001 latest_applied=mig_003",
+			},
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			provider := migrationtest.NewMockDbProvider(ctrl)
+			provider.EXPECT().GetDb().AnyTimes()
+			provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+			provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords("mig_003"), nil)
+			provider.EXPECT().MarkApplied(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			m := NewMigrator(provider)
+			m.SetOutOfOrderPolicy(tc.policy)
+			logger := &recordingLogger{messages: []string{}}
+			m.SetLogger(silentRuns{logger})
+			for _, name := range []string{"mig_001", "mig_002", "mig_003", "mig_004"} {
+				m.Add(name, func(db *sql.DB) error { return nil }, nil)
+			}
+
+			var applied []string
+			var err error
+			if len(tc.to) > 0 {
+				applied, err = m.ApplyTo(tc.to)
+			} else {
+				applied, err = m.Apply()
+			}
+
+			assert.True(t, errors.Is(err, tc.expectErr), "unexpected error %v", err)
+			assert.Equal(t, tc.expectApplied, applied)
+			assert.Equal(t, tc.expectMessages, logger.messages)
+		})
+	}
+}
+
+func TestMigrator_PlanOutOfOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	provider := migrationtest.NewMockDbProvider(ctrl)
+	provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords("mig_002"), nil)
+
+	m := NewMigrator(provider)
+	m.SetOutOfOrderPolicy(OutOfOrderFail)
+	m.Add("mig_001", func(db *sql.DB) error { return nil }, nil)
+	m.Add("mig_002", func(db *sql.DB) error { return nil }, nil)
+
+	_, err := m.Plan()
+	assert.EqualError(t, err, "migrations are out of order: mig_001 sort before the latest applied migration 'mig_002'")
+}
+
+// silentRuns passes only warnings to the logger
+type silentRuns struct {
+	*recordingLogger
+}
+
+func (silentRuns) Debug(msg string, keysAndValues ...interface{}) {}
+func (silentRuns) Info(msg string, keysAndValues ...interface{})  {}
+func (silentRuns) Error(msg string, keysAndValues ...interface{}) {}
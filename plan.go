@@ -0,0 +1,156 @@
+package mymigrate
+
+import (
+	"context"
+)
+
+// Direction is a direction of a migration run
+type Direction string
+
+const (
+	// DirectionUp means that migrations are applied
+	DirectionUp Direction = "up"
+	// DirectionDown means that migrations are reverted
+	DirectionDown Direction = "down"
+)
+
+// MigrationPlan describes what a migration run would do without doing it
+type MigrationPlan struct {
+	Direction Direction
+	Steps     []PlanStep
+}
+
+// PlanStep describes a single migration of a plan
+type PlanStep struct {
+	Name string
+	// Transactional tells whether the migration runs inside a transaction
+	Transactional bool
+	// Irreversible tells whether the migration can't be reverted once it is applied
+	Irreversible bool
+	// Statements of SQL migration in the order of execution. It is empty for golang migrations
+	Statements []string
+}
+
+// Plan returns a plan of Apply
+func (m *Migrator) Plan() (MigrationPlan, error) {
+	return m.PlanContext(context.Background())
+}
+
+// PlanContext returns a plan of ApplyContext
+func (m *Migrator) PlanContext(ctx context.Context) (MigrationPlan, error) {
+	err := m.Validate()
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+
+	records, err := m.getApplied(ctx, m.provider)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+
+	m.observePending(records)
+
+	names, err := m.pendingNames(records)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+
+	return m.plan(DirectionUp, names), nil
+}
+
+// PlanTo returns a plan of ApplyTo
+func (m *Migrator) PlanTo(name string) (MigrationPlan, error) {
+	return m.PlanToContext(context.Background(), name)
+}
+
+// PlanToContext returns a plan of ApplyToContext
+func (m *Migrator) PlanToContext(ctx context.Context, name string) (MigrationPlan, error) {
+	err := m.Validate()
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+
+	names, _, err := m.namesToApplyTo(ctx, name)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+
+	return m.plan(DirectionUp, names), nil
+}
+
+// DownPlan returns a plan of Down
+func (m *Migrator) DownPlan(number int) (MigrationPlan, error) {
+	return m.DownPlanContext(context.Background(), number)
+}
+
+// DownPlanContext returns a plan of DownContext
+func (m *Migrator) DownPlanContext(ctx context.Context, number int) (MigrationPlan, error) {
+	err := m.Validate()
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+
+	names, _, err := m.namesToDown(ctx, number)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+
+	return m.plan(DirectionDown, names), nil
+}
+
+// DownToPlan returns a plan of DownTo
+func (m *Migrator) DownToPlan(name string) (MigrationPlan, error) {
+	return m.DownToPlanContext(context.Background(), name)
+}
+
+// DownToPlanContext returns a plan of DownToContext
+func (m *Migrator) DownToPlanContext(ctx context.Context, name string) (MigrationPlan, error) {
+	err := m.Validate()
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+
+	names, _, err := m.namesToDownTo(ctx, name)
+	if err != nil {
+		return MigrationPlan{}, err
+	}
+
+	return m.plan(DirectionDown, names), nil
+}
+
+func (m *Migrator) plan(direction Direction, names []string) MigrationPlan {
+	plan := MigrationPlan{
+		Direction: direction,
+		Steps:     make([]PlanStep, 0, len(names)),
+	}
+
+	for _, name := range names {
+		step := PlanStep{Name: name}
+		if mig, ok := m.migrations[name]; ok {
+			step.Transactional = mig.transactional()
+			step.Irreversible = !mig.reversible()
+			content := mig.upSQL
+			if direction == DirectionDown {
+				content = mig.downSQL
+			}
+
+			if len(content) > 0 {
+				step.Statements = m.sqlStatements(content)
+			}
+		}
+
+		plan.Steps = append(plan.Steps, step)
+	}
+
+	return plan
+}
+
+// Names returns names of the plan's migrations in the order of execution
+func (p MigrationPlan) Names() []string {
+	names := make([]string, 0, len(p.Steps))
+	for _, step := range p.Steps {
+		names = append(names, step.Name)
+	}
+
+	return names
+}
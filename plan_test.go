@@ -0,0 +1,101 @@
+package mymigrate
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrator_Plan(t *testing.T) {
+	m := NewMigrator(nil)
+	m.getApplied = func(ctx context.Context, provider DbProvider) ([]HistoryRecord, error) {
+		return historyRecords("001_users"), nil
+	}
+	m.markApplied = func(ctx context.Context, provider DbProvider, record HistoryRecord) error {
+		t.Errorf("plan shouldn't mark migration '%s' as applied", record.Name)
+		return nil
+	}
+
+	err := m.AddFS(fstest.MapFS{
+		"001_users.sql":  {Data: []byte("-- +up\nCREATE TABLE users (id INT);\n-- +down\nDROP TABLE users;")},
+		"003_orders.sql": {Data: []byte("-- +up\nCREATE TABLE orders (id INT);\nCREATE TABLE items (id INT);\n")},
+	}, ".")
+	assert.NoError(t, err)
+
+	m.Add(
+		"002_seed",
+		func(db *sql.DB) error {
+			t.Error("plan shouldn't run migrations")
+			return nil
+		},
+		nil,
+	)
+
+	plan, err := m.Plan()
+	assert.NoError(t, err)
+	assert.EqualValues(t, MigrationPlan{
+		Direction: DirectionUp,
+		Steps: []PlanStep{
+			{Name: "002_seed", Irreversible: true},
+			{
+				Name:          "003_orders",
+				Transactional: true,
+				Irreversible:  true,
+				Statements:    []string{"CREATE TABLE orders (id INT);", "CREATE TABLE items (id INT);"},
+			},
+		},
+	}, plan)
+	assert.EqualValues(t, []string{"002_seed", "003_orders"}, plan.Names())
+
+	plan, err = m.PlanTo("002_seed")
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"002_seed"}, plan.Names())
+}
+
+func TestMigrator_DownPlan(t *testing.T) {
+	m := NewMigrator(nil)
+	m.getApplied = func(ctx context.Context, provider DbProvider) ([]HistoryRecord, error) {
+		return historyRecords("002_seed", "001_users"), nil
+	}
+	m.down = func(ctx context.Context, provider DbProvider, records []HistoryRecord) ([]string, error) {
+		t.Error("plan shouldn't down migrations")
+		return recordNames(records), nil
+	}
+
+	err := m.AddFS(fstest.MapFS{
+		"001_users.sql": {Data: []byte("-- +up\nCREATE TABLE users (id INT);\n-- +down\nDROP TABLE users;")},
+	}, ".")
+	assert.NoError(t, err)
+	m.Add("002_seed", func(db *sql.DB) error { return nil }, func(db *sql.DB) error { return nil })
+
+	plan, err := m.DownPlan(0)
+	assert.NoError(t, err)
+	assert.EqualValues(t, MigrationPlan{
+		Direction: DirectionDown,
+		Steps: []PlanStep{
+			{Name: "002_seed"},
+			{Name: "001_users", Transactional: true, Statements: []string{"DROP TABLE users;"}},
+		},
+	}, plan)
+
+	plan, err = m.DownToPlan("001_users")
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"002_seed"}, plan.Names())
+}
+
+func TestMigrator_DownPlanRefusesUnregistered(t *testing.T) {
+	m := NewMigrator(nil)
+	m.getApplied = func(ctx context.Context, provider DbProvider) ([]HistoryRecord, error) {
+		return historyRecords("003_orders", "002_seed", "001_users"), nil
+	}
+
+	noop := func(db *sql.DB) error { return nil }
+	m.Add("001_users", noop, noop)
+	m.Add("003_orders", noop, noop)
+
+	_, err := m.DownPlan(0)
+	assert.EqualError(t, err, "can't revert migration '002_seed': it isn't registered")
+}
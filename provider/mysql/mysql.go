@@ -3,19 +3,30 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/iamsalnikov/mymigrate/provider"
+	"hash/fnv"
+	"math"
+	"strings"
 	"time"
 )
 
 // Provider - migration provider for mysql db
 type Provider struct {
-	db *sql.DB
+	db       *sql.DB
+	settings provider.Settings
+	// connection holding the named lock
+	lockConn *sql.Conn
 }
 
-// NewMysqlProvider - constructor for mysql Provider
-func NewMysqlProvider(db *sql.DB) *Provider {
-	return &Provider{db: db}
+// NewMysqlProvider - constructor for mysql Provider.
+// Use provider.WithTableName and provider.WithSchema (a database name) options to change the migration history table
+func NewMysqlProvider(db *sql.DB, opts ...provider.Option) *Provider {
+	return &Provider{
+		db:       db,
+		settings: provider.NewSettings(opts...),
+	}
 }
 
 // GetDb - function returning internal db object
@@ -23,47 +34,255 @@ func (p *Provider) GetDb() *sql.DB {
 	return p.db
 }
 
-// CreateMigrationsTable - function creating migration table in db
-func (p *Provider) CreateMigrationsTable() error {
+// CreateMigrationsTable - function creating migration table in db.
+// MySQL commits DDL implicitly, so a table of an older layout is upgraded in place holding a named lock
+func (p *Provider) CreateMigrationsTable(ctx context.Context) error {
+	// the lock belongs to a session, so all queries are run on the same connection
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = getLock(ctx, conn, p.upgradeLockName())
+	if err != nil {
+		return err
+	}
+
+	err = p.createMigrationsTable(ctx, conn)
+
+	_, unlockErr := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", p.upgradeLockName())
+	if err != nil {
+		return err
+	}
+
+	return unlockErr
+}
+
+func (p *Provider) createMigrationsTable(ctx context.Context, conn *sql.Conn) error {
 	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
 		name VARCHAR(500) NOT NULL unique,
 		time timestamp,
+		checksum VARCHAR(255) NOT NULL DEFAULT '',
+		dirty BOOLEAN NOT NULL DEFAULT FALSE,
+		duration_ms BIGINT NOT NULL DEFAULT 0,
+		hostname VARCHAR(255) NOT NULL DEFAULT '',
+		os_user VARCHAR(255) NOT NULL DEFAULT '',
+		app_version VARCHAR(255) NOT NULL DEFAULT '',
+		direction VARCHAR(10) NOT NULL DEFAULT 'up',
+		error TEXT,
+		batch BIGINT NOT NULL DEFAULT 0,
+		sequence BIGINT NOT NULL DEFAULT 0,
 		PRIMARY KEY (name)
-	) engine=InnoDB`, provider.DefaultTableName)
-	_, err := p.db.Exec(query)
-	return err
+	) engine=InnoDB`, p.table())
+	_, err := conn.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id INT NOT NULL,
+		version INT NOT NULL,
+		PRIMARY KEY (id)
+	) engine=InnoDB`, p.metaTable())
+	_, err = conn.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return p.upgrade().Run(ctx, conn)
 }
 
-// GetApplied - function returning list applied migrations
-func (p *Provider) GetApplied(ctx context.Context) ([]string, error) {
-	query := fmt.Sprintf("SELECT name FROM %s ORDER BY time DESC, name DESC", provider.DefaultTableName)
+// upgrade returns upgrade of the migration history table to the current layout
+func (p *Provider) upgrade() provider.Upgrade {
+	return provider.Upgrade{
+		Table:     p.table(),
+		MetaTable: p.metaTable(),
+		ColumnDefinitions: map[string]string{
+			"checksum":    "VARCHAR(255) NOT NULL DEFAULT ''",
+			"dirty":       "BOOLEAN NOT NULL DEFAULT FALSE",
+			"duration_ms": "BIGINT NOT NULL DEFAULT 0",
+			"hostname":    "VARCHAR(255) NOT NULL DEFAULT ''",
+			"os_user":     "VARCHAR(255) NOT NULL DEFAULT ''",
+			"app_version": "VARCHAR(255) NOT NULL DEFAULT ''",
+			"direction":   "VARCHAR(10) NOT NULL DEFAULT 'up'",
+			"error":       "TEXT",
+			"batch":       "BIGINT NOT NULL DEFAULT 0",
+			"sequence":    "BIGINT NOT NULL DEFAULT 0",
+		},
+		// mysql can't read the updated table in a subquery, so rows are numbered with a session variable
+		SequenceStatements: []string{
+			"SET @mymigrate_sequence = 0",
+			fmt.Sprintf("UPDATE %s SET sequence = (@mymigrate_sequence := @mymigrate_sequence + 1) ORDER BY time, name", p.table()),
+		},
+	}
+}
+
+// GetApplied - function returning list applied migrations from the latest applied to the earliest one
+func (p *Provider) GetApplied(ctx context.Context) ([]provider.HistoryRecord, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s ORDER BY sequence DESC", provider.HistorySelectList(), p.table())
 	rows, err := p.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
-	res := make([]string, 0)
+	defer rows.Close()
+
+	res := make([]provider.HistoryRecord, 0)
 	for rows.Next() {
-		var name string
-		err := rows.Scan(&name)
+		record, err := provider.ScanRecord(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		res = append(res, name)
+		res = append(res, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
+
 	return res, nil
 }
 
-// MarkApplied - function for mark migration applied
-func (p *Provider) MarkApplied(ctx context.Context, name string, t time.Time) error {
-	query := fmt.Sprintf("INSERT INTO %s (name, time) VALUES (?, ?)", provider.DefaultTableName)
-	_, err := p.db.ExecContext(ctx, query, name, t)
+// MarkApplied - function for mark migration applied.
+// It overwrites the row of the migration if there is one, so it is used to flip a dirty row to applied and back
+func (p *Provider) MarkApplied(ctx context.Context, record provider.HistoryRecord) error {
+	_, err := p.db.ExecContext(ctx, p.markAppliedQuery(), provider.RecordArgs(record)...)
+	return err
+}
+
+// CommitsDDL - function telling that MySQL commits DDL statements implicitly,
+// so a transactional migration is marked as dirty before it runs and the marker stays if it fails
+func (p *Provider) CommitsDDL() bool {
+	return true
+}
+
+// BackslashEscapes - function telling that MySQL treats a backslash inside strings as an escape character,
+// so statements of SQL migrations are split accordingly. It doesn't account for NO_BACKSLASH_ESCAPES SQL mode
+func (p *Provider) BackslashEscapes() bool {
+	return true
+}
+
+// MarkAppliedTx - function for mark migration applied inside a transaction
+func (p *Provider) MarkAppliedTx(ctx context.Context, tx *sql.Tx, record provider.HistoryRecord) error {
+	_, err := tx.ExecContext(ctx, p.markAppliedQuery(), provider.RecordArgs(record)...)
 	return err
 }
 
+func (p *Provider) markAppliedQuery() string {
+	placeholders := make([]string, 0, len(provider.HistoryColumns))
+	updates := make([]string, 0, len(provider.HistoryColumns))
+	for _, column := range provider.HistoryColumns {
+		placeholders = append(placeholders, "?")
+		if column != "name" {
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", column, column))
+		}
+	}
+
+	// the sequence of a new row follows the latest one and isn't changed when the row is overwritten.
+	// mysql can't read the inserted table in a subquery of VALUES, so INSERT ... SELECT is used
+	return fmt.Sprintf("INSERT INTO %s (%s) SELECT %s, COALESCE(MAX(sequence), 0) + 1 FROM %s ON DUPLICATE KEY UPDATE %s",
+		p.table(), provider.HistorySelectList(), strings.Join(placeholders, ", "), p.table(), strings.Join(updates, ", "))
+}
+
 // DeleteApplied - function for delete migration from applied list
 func (p *Provider) DeleteApplied(ctx context.Context, name string) error {
-	query := fmt.Sprintf("DELETE FROM %s WHERE name=?", provider.DefaultTableName)
-	_, err := p.db.ExecContext(ctx, query, name)
+	_, err := p.db.ExecContext(ctx, p.deleteAppliedQuery(), name)
+	return err
+}
+
+// DeleteAppliedTx - function for delete migration from applied list inside a transaction
+func (p *Provider) DeleteAppliedTx(ctx context.Context, tx *sql.Tx, name string) error {
+	_, err := tx.ExecContext(ctx, p.deleteAppliedQuery(), name)
+	return err
+}
+
+func (p *Provider) deleteAppliedQuery() string {
+	return fmt.Sprintf("DELETE FROM %s WHERE name=?", p.table())
+}
+
+// Lock - function acquiring a named lock for migrations with GET_LOCK.
+// It waits until the lock is released by another process or ctx deadline is exceeded
+func (p *Provider) Lock(ctx context.Context) error {
+	if p.lockConn != nil {
+		return errors.New("migration lock is already acquired")
+	}
+
+	// named locks belong to a session, so we have to keep the connection until Unlock
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = getLock(ctx, conn, p.lockName())
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	p.lockConn = conn
+	return nil
+}
+
+// Unlock - function releasing the named lock acquired by Lock
+func (p *Provider) Unlock(ctx context.Context) error {
+	if p.lockConn == nil {
+		return errors.New("migration lock isn't acquired")
+	}
+
+	_, err := p.lockConn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", p.lockName())
+	closeErr := p.lockConn.Close()
+	p.lockConn = nil
+
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
+
+// getLock acquires the named lock with GET_LOCK waiting until ctx deadline is exceeded
+func getLock(ctx context.Context, conn *sql.Conn, name string) error {
+	// negative timeout means infinite waiting
+	timeout := -1
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = int(math.Ceil(time.Until(deadline).Seconds()))
+	}
+
+	var acquired sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, timeout).Scan(&acquired)
+	if err == nil && acquired.Int64 != 1 {
+		err = errors.New("timeout waiting for migration lock")
+	}
+
 	return err
 }
+
+// upgradeLockName returns name of the lock for creating and upgrading the migration table.
+// It differs from lockName, so the table can be upgraded while the migration lock is held
+func (p *Provider) upgradeLockName() string {
+	return "mymigrate.upgrade." + nameHash(p.settings.FullName())
+}
+
+// metaTable returns quoted name of the table holding version of the migration history table
+func (p *Provider) metaTable() string {
+	return p.settings.QualifiedName(p.settings.TableName+"_meta", "`")
+}
+
+// lockName returns name of the lock built from the migration table name
+func (p *Provider) lockName() string {
+	return "mymigrate." + nameHash(p.settings.FullName())
+}
+
+// nameHash returns hash of the table name for lock names. GET_LOCK accepts names up to 64 characters,
+// and names of a schema and a table are up to 64 characters each
+func nameHash(name string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// table returns quoted name of the migration history table
+func (p *Provider) table() string {
+	return p.settings.QualifiedName(p.settings.TableName, "`")
+}
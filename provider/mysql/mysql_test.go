@@ -2,12 +2,14 @@ package mysql_test
 
 import (
 	"context"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/iamsalnikov/mymigrate/provider"
 	"github.com/iamsalnikov/mymigrate/provider/mysql"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 	"time"
 )
@@ -21,12 +23,12 @@ func TestMysqlProvider_CreateMigrationsTable(t *testing.T) {
 	}{
 		"All is ok": {
 			execError:   nil,
-			expectQuery: fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s ( name VARCHAR(500) NOT NULL unique, time timestamp, PRIMARY KEY (name) ) engine=InnoDB", provider.DefaultTableName),
+			expectQuery: fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` ( name VARCHAR(500) NOT NULL unique, time timestamp, checksum VARCHAR(255) NOT NULL DEFAULT '', dirty BOOLEAN NOT NULL DEFAULT FALSE, duration_ms BIGINT NOT NULL DEFAULT 0, hostname VARCHAR(255) NOT NULL DEFAULT '', os_user VARCHAR(255) NOT NULL DEFAULT '', app_version VARCHAR(255) NOT NULL DEFAULT '', direction VARCHAR(10) NOT NULL DEFAULT 'up', error TEXT, batch BIGINT NOT NULL DEFAULT 0, sequence BIGINT NOT NULL DEFAULT 0, PRIMARY KEY (name) ) engine=InnoDB", provider.DefaultTableName),
 			expectErr:   nil,
 		},
 		"db error": {
 			execError:   errors.New("some db error"),
-			expectQuery: fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s ( name VARCHAR(500) NOT NULL unique, time timestamp, PRIMARY KEY (name) ) engine=InnoDB", provider.DefaultTableName),
+			expectQuery: fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` ( name VARCHAR(500) NOT NULL unique, time timestamp, checksum VARCHAR(255) NOT NULL DEFAULT '', dirty BOOLEAN NOT NULL DEFAULT FALSE, duration_ms BIGINT NOT NULL DEFAULT 0, hostname VARCHAR(255) NOT NULL DEFAULT '', os_user VARCHAR(255) NOT NULL DEFAULT '', app_version VARCHAR(255) NOT NULL DEFAULT '', direction VARCHAR(10) NOT NULL DEFAULT 'up', error TEXT, batch BIGINT NOT NULL DEFAULT 0, sequence BIGINT NOT NULL DEFAULT 0, PRIMARY KEY (name) ) engine=InnoDB", provider.DefaultTableName),
 			expectErr:   errors.New("some db error"),
 		},
 	}
@@ -37,19 +39,110 @@ func TestMysqlProvider_CreateMigrationsTable(t *testing.T) {
 			defer db.Close()
 			assert.NoError(t, err)
 
+			mock.ExpectQuery("SELECT GET_LOCK(?, ?)").
+				WithArgs("mymigrate.upgrade.9f6a95c9dd82fff5", -1).
+				WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
 			mock.ExpectExec(c.expectQuery).
 				WillReturnResult(sqlmock.NewResult(1, 1)).
 				WillReturnError(c.execError)
+			if c.execError == nil {
+				mock.ExpectExec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s_meta` ( id INT NOT NULL, version INT NOT NULL, PRIMARY KEY (id) ) engine=InnoDB", provider.DefaultTableName)).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(fmt.Sprintf("SELECT version FROM `%s_meta` WHERE id = 1", provider.DefaultTableName)).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(provider.HistoryVersion))
+			}
+			mock.ExpectExec("SELECT RELEASE_LOCK(?)").
+				WithArgs("mymigrate.upgrade.9f6a95c9dd82fff5").
+				WillReturnResult(sqlmock.NewResult(0, 0))
 
 			p := mysql.NewMysqlProvider(db)
-			err = p.CreateMigrationsTable()
+			err = p.CreateMigrationsTable(context.Background())
 
 			assert.Equal(t, c.expectErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 
 }
 
+func TestMysqlProvider_CreateMigrationsTableUpgrade(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectQuery("SELECT GET_LOCK(?, ?)").
+		WithArgs("mymigrate.upgrade.9f6a95c9dd82fff5", -1).
+		WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+	mock.ExpectExec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s` ( name VARCHAR(500) NOT NULL unique, time timestamp, checksum VARCHAR(255) NOT NULL DEFAULT '', dirty BOOLEAN NOT NULL DEFAULT FALSE, duration_ms BIGINT NOT NULL DEFAULT 0, hostname VARCHAR(255) NOT NULL DEFAULT '', os_user VARCHAR(255) NOT NULL DEFAULT '', app_version VARCHAR(255) NOT NULL DEFAULT '', direction VARCHAR(10) NOT NULL DEFAULT 'up', error TEXT, batch BIGINT NOT NULL DEFAULT 0, sequence BIGINT NOT NULL DEFAULT 0, PRIMARY KEY (name) ) engine=InnoDB", provider.DefaultTableName)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s_meta` ( id INT NOT NULL, version INT NOT NULL, PRIMARY KEY (id) ) engine=InnoDB", provider.DefaultTableName)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(fmt.Sprintf("SELECT version FROM `%s_meta` WHERE id = 1", provider.DefaultTableName)).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectQuery(fmt.Sprintf("SELECT * FROM `%s` WHERE 1 = 0", provider.DefaultTableName)).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "time"}))
+	for _, column := range []string{
+		"checksum VARCHAR(255) NOT NULL DEFAULT ''",
+		"dirty BOOLEAN NOT NULL DEFAULT FALSE",
+		"duration_ms BIGINT NOT NULL DEFAULT 0",
+		"hostname VARCHAR(255) NOT NULL DEFAULT ''",
+		"os_user VARCHAR(255) NOT NULL DEFAULT ''",
+		"app_version VARCHAR(255) NOT NULL DEFAULT ''",
+		"direction VARCHAR(10) NOT NULL DEFAULT 'up'",
+		"error TEXT",
+		"batch BIGINT NOT NULL DEFAULT 0",
+		"sequence BIGINT NOT NULL DEFAULT 0",
+	} {
+		mock.ExpectExec(fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN %s", provider.DefaultTableName, column)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec("SET @mymigrate_sequence = 0").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(fmt.Sprintf("UPDATE `%s` SET sequence = (@mymigrate_sequence := @mymigrate_sequence + 1) ORDER BY time, name", provider.DefaultTableName)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(fmt.Sprintf("DELETE FROM `%s_meta`", provider.DefaultTableName)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(fmt.Sprintf("INSERT INTO `%s_meta` (id, version) VALUES (1, %d)", provider.DefaultTableName, provider.HistoryVersion)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("SELECT RELEASE_LOCK(?)").
+		WithArgs("mymigrate.upgrade.9f6a95c9dd82fff5").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	p := mysql.NewMysqlProvider(db)
+
+	assert.NoError(t, p.CreateMigrationsTable(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMysqlProvider_TableSettings(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectQuery("SELECT GET_LOCK(?, ?)").
+		WithArgs("mymigrate.upgrade.f2a004f1340c35c4", -1).
+		WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS `meta`.`my``history` ( name VARCHAR(500) NOT NULL unique, time timestamp, checksum VARCHAR(255) NOT NULL DEFAULT '', dirty BOOLEAN NOT NULL DEFAULT FALSE, duration_ms BIGINT NOT NULL DEFAULT 0, hostname VARCHAR(255) NOT NULL DEFAULT '', os_user VARCHAR(255) NOT NULL DEFAULT '', app_version VARCHAR(255) NOT NULL DEFAULT '', direction VARCHAR(10) NOT NULL DEFAULT 'up', error TEXT, batch BIGINT NOT NULL DEFAULT 0, sequence BIGINT NOT NULL DEFAULT 0, PRIMARY KEY (name) ) engine=InnoDB").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS `meta`.`my``history_meta` ( id INT NOT NULL, version INT NOT NULL, PRIMARY KEY (id) ) engine=InnoDB").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version FROM `meta`.`my``history_meta` WHERE id = 1").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(provider.HistoryVersion))
+	mock.ExpectExec("SELECT RELEASE_LOCK(?)").
+		WithArgs("mymigrate.upgrade.f2a004f1340c35c4").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence FROM `meta`.`my``history` ORDER BY sequence DESC").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch", "sequence"}))
+
+	p := mysql.NewMysqlProvider(db, provider.WithTableName("my`history"), provider.WithSchema("meta"))
+
+	assert.NoError(t, p.CreateMigrationsTable(context.Background()))
+
+	_, err = p.GetApplied(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestMysqlProvider_GetDb(t *testing.T) {
 	db, _, err := sqlmock.New()
 	defer db.Close()
@@ -61,35 +154,50 @@ func TestMysqlProvider_GetDb(t *testing.T) {
 }
 
 func TestMysqlProvider_GetApplied(t *testing.T) {
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
 	cases := map[string]struct {
 		execError error
 		execRows  *sqlmock.Rows
 
 		expectQuery  string
 		expectErr    error
-		expectResult []string
+		expectResult []provider.HistoryRecord
 	}{
 		"empty migration table": {
 			execError:    nil,
-			execRows:     sqlmock.NewRows([]string{"name"}),
-			expectQuery:  fmt.Sprintf("SELECT name FROM %s ORDER BY time DESC, name DESC", provider.DefaultTableName),
+			execRows:     sqlmock.NewRows([]string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch", "sequence"}),
+			expectQuery:  fmt.Sprintf("SELECT name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence FROM `%s` ORDER BY sequence DESC", provider.DefaultTableName),
 			expectErr:    nil,
-			expectResult: []string{},
+			expectResult: []provider.HistoryRecord{},
 		},
 		"all is ok": {
-			execError:    nil,
-			execRows:     sqlmock.NewRows([]string{"name"}).AddRow("migration_1").AddRow("migration_2"),
-			expectQuery:  fmt.Sprintf("SELECT name FROM %s ORDER BY time DESC, name DESC", provider.DefaultTableName),
-			expectErr:    nil,
-			expectResult: []string{"migration_1", "migration_2"},
+			execError: nil,
+			execRows: sqlmock.NewRows([]string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch", "sequence"}).
+				AddRow("migration_1", now, "abc", false, int64(1500), "host-1", "deploy", "1.2.3", "up", nil, int64(3), int64(2)).
+				AddRow("migration_2", now.Format("2006-01-02 15:04:05"), "", true, int64(0), "", "", "", "down", "some error", nil, int64(1)),
+			expectQuery: fmt.Sprintf("SELECT name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence FROM `%s` ORDER BY sequence DESC", provider.DefaultTableName),
+			expectErr:   nil,
+			expectResult: []provider.HistoryRecord{
+				{Name: "migration_1", AppliedAt: now, Checksum: "abc", Duration: 1500 * time.Millisecond, Hostname: "host-1", OSUser: "deploy", AppVersion: "1.2.3", Direction: "up", Batch: 3, Sequence: 2},
+				{Name: "migration_2", AppliedAt: now, Dirty: true, Direction: "down", Error: "some error", Sequence: 1},
+			},
 		},
 		"db error": {
 			execError:    errors.New("some db error"),
-			execRows:     sqlmock.NewRows([]string{"name"}),
-			expectQuery:  fmt.Sprintf("SELECT name FROM %s ORDER BY time DESC, name DESC", provider.DefaultTableName),
+			execRows:     sqlmock.NewRows([]string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch", "sequence"}),
+			expectQuery:  fmt.Sprintf("SELECT name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence FROM `%s` ORDER BY sequence DESC", provider.DefaultTableName),
 			expectErr:    errors.New("some db error"),
 			expectResult: nil,
 		},
+		"rows error": {
+			execError: nil,
+			execRows: sqlmock.NewRows([]string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch", "sequence"}).
+				AddRow("migration_1", time.Now(), "", false, int64(0), "", "", "", "up", nil, int64(1), int64(1)).
+				RowError(0, errors.New("some rows error")),
+			expectQuery:  fmt.Sprintf("SELECT name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence FROM `%s` ORDER BY sequence DESC", provider.DefaultTableName),
+			expectErr:    errors.New("some rows error"),
+			expectResult: nil,
+		},
 	}
 
 	for name, c := range cases {
@@ -113,29 +221,26 @@ func TestMysqlProvider_GetApplied(t *testing.T) {
 func TestMysqlProvider_MarkApplied(t *testing.T) {
 	now := time.Now()
 	cases := map[string]struct {
-		name string
-		time time.Time
+		record provider.HistoryRecord
 
 		execError error
 
 		expectQuery string
 		expectErr   error
-		expectArgs  []interface{}
+		expectArgs  []driver.Value
 	}{
 		"all is ok": {
-			name:        "migration_1",
-			time:        now,
+			record:      provider.HistoryRecord{Name: "migration_1", AppliedAt: now, Checksum: "abc", Duration: 1500 * time.Millisecond, Hostname: "host-1", OSUser: "deploy", AppVersion: "1.2.3", Direction: "up", Batch: 2},
 			execError:   nil,
-			expectQuery: fmt.Sprintf("INSERT INTO %s (name, time) VALUES (?, ?)", provider.DefaultTableName),
-			expectArgs:  []interface{}{"migration_1", now},
+			expectQuery: fmt.Sprintf("INSERT INTO `%s` (name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence) SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(MAX(sequence), 0) + 1 FROM `%s` ON DUPLICATE KEY UPDATE time = VALUES(time), checksum = VALUES(checksum), dirty = VALUES(dirty), duration_ms = VALUES(duration_ms), hostname = VALUES(hostname), os_user = VALUES(os_user), app_version = VALUES(app_version), direction = VALUES(direction), error = VALUES(error), batch = VALUES(batch)", provider.DefaultTableName, provider.DefaultTableName),
+			expectArgs:  []driver.Value{"migration_1", now, "abc", false, int64(1500), "host-1", "deploy", "1.2.3", "up", "", int64(2)},
 			expectErr:   nil,
 		},
 		"db error": {
-			name:        "migration_2",
-			time:        now,
+			record:      provider.HistoryRecord{Name: "migration_2", AppliedAt: now, Checksum: "abc", Dirty: true, Direction: "down", Error: "boom"},
 			execError:   errors.New("some db error"),
-			expectQuery: fmt.Sprintf("INSERT INTO %s (name, time) VALUES (?, ?)", provider.DefaultTableName),
-			expectArgs:  []interface{}{"migration_2", now},
+			expectQuery: fmt.Sprintf("INSERT INTO `%s` (name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence) SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(MAX(sequence), 0) + 1 FROM `%s` ON DUPLICATE KEY UPDATE time = VALUES(time), checksum = VALUES(checksum), dirty = VALUES(dirty), duration_ms = VALUES(duration_ms), hostname = VALUES(hostname), os_user = VALUES(os_user), app_version = VALUES(app_version), direction = VALUES(direction), error = VALUES(error), batch = VALUES(batch)", provider.DefaultTableName, provider.DefaultTableName),
+			expectArgs:  []driver.Value{"migration_2", now, "abc", true, int64(0), "", "", "", "down", "boom", int64(0)},
 			expectErr:   errors.New("some db error"),
 		},
 	}
@@ -146,13 +251,13 @@ func TestMysqlProvider_MarkApplied(t *testing.T) {
 			assert.NoError(t, err)
 
 			mock.ExpectExec(c.expectQuery).
-				WithArgs(c.expectArgs[0], c.expectArgs[1]).
+				WithArgs(c.expectArgs...).
 				WillReturnResult(sqlmock.NewResult(1, 1)).
 				WillReturnError(c.execError)
 
 			p := mysql.NewMysqlProvider(db)
 
-			err = p.MarkApplied(context.Background(), c.name, c.time)
+			err = p.MarkApplied(context.Background(), c.record)
 
 			assert.Equal(t, c.expectErr, err)
 		})
@@ -173,14 +278,14 @@ func TestMysqlProvider_DeleteApplied(t *testing.T) {
 		"all is ok": {
 			name:        "migration_1",
 			execError:   nil,
-			expectQuery: fmt.Sprintf("DELETE FROM %s WHERE name=?", provider.DefaultTableName),
+			expectQuery: fmt.Sprintf("DELETE FROM `%s` WHERE name=?", provider.DefaultTableName),
 			expectArgs:  []interface{}{"migration_1"},
 			expectErr:   nil,
 		},
 		"db error": {
 			name:        "migration_2",
 			execError:   errors.New("some db error"),
-			expectQuery: fmt.Sprintf("DELETE FROM %s WHERE name=?", provider.DefaultTableName),
+			expectQuery: fmt.Sprintf("DELETE FROM `%s` WHERE name=?", provider.DefaultTableName),
 			expectArgs:  []interface{}{"migration_2"},
 			expectErr:   errors.New("some db error"),
 		},
@@ -204,3 +309,136 @@ func TestMysqlProvider_DeleteApplied(t *testing.T) {
 		})
 	}
 }
+
+func TestMysqlProvider_MarkAppliedTx(t *testing.T) {
+	now := time.Now()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(fmt.Sprintf("INSERT INTO `%s` (name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence) SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(MAX(sequence), 0) + 1 FROM `%s` ON DUPLICATE KEY UPDATE time = VALUES(time), checksum = VALUES(checksum), dirty = VALUES(dirty), duration_ms = VALUES(duration_ms), hostname = VALUES(hostname), os_user = VALUES(os_user), app_version = VALUES(app_version), direction = VALUES(direction), error = VALUES(error), batch = VALUES(batch)", provider.DefaultTableName, provider.DefaultTableName)).
+		WithArgs("migration_1", now, "abc", false, int64(0), "", "", "", "", "", int64(0)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+
+	p := mysql.NewMysqlProvider(db)
+	err = p.MarkAppliedTx(context.Background(), tx, provider.HistoryRecord{Name: "migration_1", AppliedAt: now, Checksum: "abc"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, tx.Commit())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMysqlProvider_DeleteAppliedTx(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(fmt.Sprintf("DELETE FROM `%s` WHERE name=?", provider.DefaultTableName)).
+		WithArgs("migration_1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+
+	p := mysql.NewMysqlProvider(db)
+	err = p.DeleteAppliedTx(context.Background(), tx, "migration_1")
+	assert.NoError(t, err)
+
+	assert.NoError(t, tx.Commit())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMysqlProvider_Lock(t *testing.T) {
+	cases := map[string]struct {
+		lockResult interface{}
+		expectErr  error
+	}{
+		"lock is acquired": {
+			lockResult: 1,
+			expectErr:  nil,
+		},
+		"timeout": {
+			lockResult: 0,
+			expectErr:  errors.New("timeout waiting for migration lock"),
+		},
+		"lock error": {
+			lockResult: nil,
+			expectErr:  errors.New("timeout waiting for migration lock"),
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			defer db.Close()
+			assert.NoError(t, err)
+
+			mock.ExpectQuery("SELECT GET_LOCK(?, ?)").
+				WithArgs("mymigrate.9f6a95c9dd82fff5", 5).
+				WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(c.lockResult))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			p := mysql.NewMysqlProvider(db)
+			err = p.Lock(ctx)
+
+			assert.Equal(t, c.expectErr, err)
+		})
+	}
+}
+
+func TestMysqlProvider_Unlock(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectQuery("SELECT GET_LOCK(?, ?)").
+		WithArgs("mymigrate.9f6a95c9dd82fff5", -1).
+		WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+	mock.ExpectExec("SELECT RELEASE_LOCK(?)").
+		WithArgs("mymigrate.9f6a95c9dd82fff5").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	p := mysql.NewMysqlProvider(db)
+
+	assert.NoError(t, p.Lock(context.Background()))
+	assert.NoError(t, p.Unlock(context.Background()))
+	assert.Error(t, p.Unlock(context.Background()), "lock can't be released twice")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// shortLockName matches lock names that GET_LOCK accepts
+type shortLockName struct{}
+
+func (shortLockName) Match(v driver.Value) bool {
+	name, ok := v.(string)
+	return ok && len(name) <= 64
+}
+
+func TestMysqlProvider_LockLongName(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectQuery("SELECT GET_LOCK(?, ?)").
+		WithArgs(shortLockName{}, -1).
+		WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(1))
+
+	p := mysql.NewMysqlProvider(
+		db,
+		provider.WithSchema(strings.Repeat("s", 64)),
+		provider.WithTableName(strings.Repeat("t", 64)),
+	)
+
+	assert.NoError(t, p.Lock(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,20 +3,29 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"time"
+	"hash/fnv"
+	"strings"
 
 	"github.com/iamsalnikov/mymigrate/provider"
 )
 
 // Provider - migration provider for postgres db
 type Provider struct {
-	db *sql.DB
+	db       *sql.DB
+	settings provider.Settings
+	// connection holding the advisory lock
+	lockConn *sql.Conn
 }
 
-// NewPsqlProvider - constructor for postgres Provider
-func NewPsqlProvider(db *sql.DB) *Provider {
-	return &Provider{db: db}
+// NewPsqlProvider - constructor for postgres Provider.
+// Use provider.WithTableName and provider.WithSchema options to change the migration history table
+func NewPsqlProvider(db *sql.DB, opts ...provider.Option) *Provider {
+	return &Provider{
+		db:       db,
+		settings: provider.NewSettings(opts...),
+	}
 }
 
 // GetDb - function returning internal db object
@@ -24,49 +33,221 @@ func (p *Provider) GetDb() *sql.DB {
 	return p.db
 }
 
-// CreateMigrationsTable - function creating migration table in db
-func (p *Provider) CreateMigrationsTable() error {
+// CreateMigrationsTable - function creating migration table in db.
+// A table of an older layout is upgraded in place inside a transaction
+func (p *Provider) CreateMigrationsTable(ctx context.Context) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = p.createMigrationsTable(ctx, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p *Provider) createMigrationsTable(ctx context.Context, tx *sql.Tx) error {
+	// concurrent processes wait for each other here, so only one of them creates and upgrades the table
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", p.upgradeLockKey())
+	if err != nil {
+		return err
+	}
+
 	query := fmt.Sprintf(`create table if not exists %s
 		(
-			name varchar(500) not null constraint %s_pk primary key,
-			time timestamp
+			name varchar(500) not null constraint %s primary key,
+			time timestamptz,
+			checksum varchar(255) not null default '',
+			dirty boolean not null default false,
+			duration_ms bigint not null default 0,
+			hostname varchar(255) not null default '',
+			os_user varchar(255) not null default '',
+			app_version varchar(255) not null default '',
+			direction varchar(10) not null default 'up',
+			error text not null default '',
+			batch bigint not null default 0,
+			sequence bigint not null default 0
 		);
-		create unique index if not exists %s_name_uindex on %s (name);`, provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName)
+		create unique index if not exists %s on %s (name);
+		create table if not exists %s
+		(
+			id integer not null primary key,
+			version integer not null
+		);`, p.table(), p.ident(p.settings.TableName+"_pk"), p.ident(p.settings.TableName+"_name_uindex"), p.table(), p.metaTable())
 
-	_, err := p.db.Exec(query)
-	return err
+	_, err = tx.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return p.upgrade().Run(ctx, tx)
 }
 
-// GetApplied - function returning list applied migrations
-func (p *Provider) GetApplied(ctx context.Context) ([]string, error) {
-	query := fmt.Sprintf("SELECT name FROM %s ORDER BY time DESC, name DESC", provider.DefaultTableName)
+// upgrade returns upgrade of the migration history table to the current layout
+func (p *Provider) upgrade() provider.Upgrade {
+	return provider.Upgrade{
+		Table:     p.table(),
+		MetaTable: p.metaTable(),
+		ColumnDefinitions: map[string]string{
+			"checksum":    "varchar(255) not null default ''",
+			"dirty":       "boolean not null default false",
+			"duration_ms": "bigint not null default 0",
+			"hostname":    "varchar(255) not null default ''",
+			"os_user":     "varchar(255) not null default ''",
+			"app_version": "varchar(255) not null default ''",
+			"direction":   "varchar(10) not null default 'up'",
+			"error":       "text not null default ''",
+			"batch":       "bigint not null default 0",
+			"sequence":    "bigint not null default 0",
+		},
+		SequenceStatements: []string{
+			fmt.Sprintf("ALTER TABLE %s ALTER COLUMN time TYPE timestamptz", p.table()),
+			provider.SequenceBackfill(p.table()),
+		},
+	}
+}
+
+// GetApplied - function returning list applied migrations from the latest applied to the earliest one
+func (p *Provider) GetApplied(ctx context.Context) ([]provider.HistoryRecord, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s ORDER BY sequence DESC", provider.HistorySelectList(), p.table())
 	rows, err := p.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
-	res := make([]string, 0)
+	defer rows.Close()
+
+	res := make([]provider.HistoryRecord, 0)
 	for rows.Next() {
-		var name string
-		err := rows.Scan(&name)
+		record, err := provider.ScanRecord(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		res = append(res, name)
+		res = append(res, record)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return res, nil
 }
 
-// MarkApplied - function for mark migration applied
-func (p *Provider) MarkApplied(ctx context.Context, name string, t time.Time) error {
-	query := fmt.Sprintf("INSERT INTO %s (name, time) VALUES ($1, $2)", provider.DefaultTableName)
-	_, err := p.db.ExecContext(ctx, query, name, t)
+// MarkApplied - function for mark migration applied.
+// It overwrites the row of the migration if there is one, so it is used to flip a dirty row to applied and back
+func (p *Provider) MarkApplied(ctx context.Context, record provider.HistoryRecord) error {
+	_, err := p.db.ExecContext(ctx, p.markAppliedQuery(), provider.RecordArgs(record)...)
 	return err
 }
 
+// MarkAppliedTx - function for mark migration applied inside a transaction
+func (p *Provider) MarkAppliedTx(ctx context.Context, tx *sql.Tx, record provider.HistoryRecord) error {
+	_, err := tx.ExecContext(ctx, p.markAppliedQuery(), provider.RecordArgs(record)...)
+	return err
+}
+
+func (p *Provider) markAppliedQuery() string {
+	placeholders := make([]string, 0, len(provider.HistoryColumns))
+	updates := make([]string, 0, len(provider.HistoryColumns))
+	for i, column := range provider.HistoryColumns {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+		if column != "name" {
+			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+		}
+	}
+
+	// the sequence of a new row follows the latest one and isn't changed when the row is overwritten
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s, (SELECT COALESCE(MAX(sequence), 0) + 1 FROM %s)) ON CONFLICT (name) DO UPDATE SET %s",
+		p.table(), provider.HistorySelectList(), strings.Join(placeholders, ", "), p.table(), strings.Join(updates, ", "))
+}
+
 // DeleteApplied - function for delete migration from applied list
 func (p *Provider) DeleteApplied(ctx context.Context, name string) error {
-	query := fmt.Sprintf("DELETE FROM %s WHERE name=$1", provider.DefaultTableName)
-	_, err := p.db.ExecContext(ctx, query, name)
+	_, err := p.db.ExecContext(ctx, p.deleteAppliedQuery(), name)
 	return err
 }
+
+// DeleteAppliedTx - function for delete migration from applied list inside a transaction
+func (p *Provider) DeleteAppliedTx(ctx context.Context, tx *sql.Tx, name string) error {
+	_, err := tx.ExecContext(ctx, p.deleteAppliedQuery(), name)
+	return err
+}
+
+func (p *Provider) deleteAppliedQuery() string {
+	return fmt.Sprintf("DELETE FROM %s WHERE name=$1", p.table())
+}
+
+// Lock - function acquiring a session-level advisory lock for migrations.
+// It waits until the lock is released by another process or ctx is done
+func (p *Provider) Lock(ctx context.Context) error {
+	if p.lockConn != nil {
+		return errors.New("migration lock is already acquired")
+	}
+
+	// advisory locks belong to a session, so we have to keep the connection until Unlock
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", p.lockKey())
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	p.lockConn = conn
+	return nil
+}
+
+// Unlock - function releasing the advisory lock acquired by Lock
+func (p *Provider) Unlock(ctx context.Context) error {
+	if p.lockConn == nil {
+		return errors.New("migration lock isn't acquired")
+	}
+
+	_, err := p.lockConn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", p.lockKey())
+	closeErr := p.lockConn.Close()
+	p.lockConn = nil
+
+	if err != nil {
+		return err
+	}
+
+	return closeErr
+}
+
+// lockKey returns advisory lock key built from the migration table name
+func (p *Provider) lockKey() int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(p.settings.FullName()))
+
+	return int64(h.Sum64())
+}
+
+// upgradeLockKey returns advisory lock key for creating and upgrading the migration table.
+// It differs from lockKey, so the table can be upgraded while the migration lock is held
+func (p *Provider) upgradeLockKey() int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("upgrade." + p.settings.FullName()))
+
+	return int64(h.Sum64())
+}
+
+// metaTable returns quoted name of the table holding version of the migration history table
+func (p *Provider) metaTable() string {
+	return p.settings.QualifiedName(p.settings.TableName+"_meta", `"`)
+}
+
+// table returns quoted name of the migration history table
+func (p *Provider) table() string {
+	return p.settings.QualifiedName(p.settings.TableName, `"`)
+}
+
+// ident returns quoted identifier
+func (p *Provider) ident(name string) string {
+	return provider.QuoteIdent(name, `"`)
+}
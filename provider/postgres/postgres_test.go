@@ -2,6 +2,7 @@ package postgres_test
 
 import (
 	"context"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"testing"
@@ -22,12 +23,12 @@ func TestPsqlProvider_CreateMigrationsTable(t *testing.T) {
 	}{
 		"All is ok": {
 			execError:   nil,
-			expectQuery: fmt.Sprintf("create table if not exists %s ( name varchar(500) not null constraint %s_pk primary key, time timestamp ); create unique index if not exists %s_name_uindex on %s (name);", provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName),
+			expectQuery: fmt.Sprintf("create table if not exists \"%s\" ( name varchar(500) not null constraint \"%s_pk\" primary key, time timestamptz, checksum varchar(255) not null default '', dirty boolean not null default false, duration_ms bigint not null default 0, hostname varchar(255) not null default '', os_user varchar(255) not null default '', app_version varchar(255) not null default '', direction varchar(10) not null default 'up', error text not null default '', batch bigint not null default 0, sequence bigint not null default 0 ); create unique index if not exists \"%s_name_uindex\" on \"%s\" (name); create table if not exists \"%s_meta\" ( id integer not null primary key, version integer not null );", provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName),
 			expectErr:   nil,
 		},
 		"db error": {
 			execError:   errors.New("some db error"),
-			expectQuery: fmt.Sprintf("create table if not exists %s ( name varchar(500) not null constraint %s_pk primary key, time timestamp ); create unique index if not exists %s_name_uindex on %s (name);", provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName),
+			expectQuery: fmt.Sprintf("create table if not exists \"%s\" ( name varchar(500) not null constraint \"%s_pk\" primary key, time timestamptz, checksum varchar(255) not null default '', dirty boolean not null default false, duration_ms bigint not null default 0, hostname varchar(255) not null default '', os_user varchar(255) not null default '', app_version varchar(255) not null default '', direction varchar(10) not null default 'up', error text not null default '', batch bigint not null default 0, sequence bigint not null default 0 ); create unique index if not exists \"%s_name_uindex\" on \"%s\" (name); create table if not exists \"%s_meta\" ( id integer not null primary key, version integer not null );", provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName),
 			expectErr:   errors.New("some db error"),
 		},
 	}
@@ -38,19 +39,103 @@ func TestPsqlProvider_CreateMigrationsTable(t *testing.T) {
 			defer db.Close()
 			assert.NoError(t, err)
 
+			mock.ExpectBegin()
+			mock.ExpectExec("SELECT pg_advisory_xact_lock($1)").
+				WithArgs(sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 0))
 			mock.ExpectExec(c.expectQuery).
 				WillReturnResult(sqlmock.NewResult(1, 1)).
 				WillReturnError(c.execError)
+			if c.execError == nil {
+				mock.ExpectQuery(fmt.Sprintf("SELECT version FROM \"%s_meta\" WHERE id = 1", provider.DefaultTableName)).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(provider.HistoryVersion))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
 
 			p := postgres.NewPsqlProvider(db)
-			err = p.CreateMigrationsTable()
+			err = p.CreateMigrationsTable(context.Background())
 
 			assert.Equal(t, c.expectErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 
 }
 
+func TestPsqlProvider_CreateMigrationsTableUpgrade(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock($1)").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(fmt.Sprintf("create table if not exists \"%s\" ( name varchar(500) not null constraint \"%s_pk\" primary key, time timestamptz, checksum varchar(255) not null default '', dirty boolean not null default false, duration_ms bigint not null default 0, hostname varchar(255) not null default '', os_user varchar(255) not null default '', app_version varchar(255) not null default '', direction varchar(10) not null default 'up', error text not null default '', batch bigint not null default 0, sequence bigint not null default 0 ); create unique index if not exists \"%s_name_uindex\" on \"%s\" (name); create table if not exists \"%s_meta\" ( id integer not null primary key, version integer not null );", provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(fmt.Sprintf("SELECT version FROM \"%s_meta\" WHERE id = 1", provider.DefaultTableName)).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectQuery(fmt.Sprintf("SELECT * FROM \"%s\" WHERE 1 = 0", provider.DefaultTableName)).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "time"}))
+	for _, column := range []string{
+		"checksum varchar(255) not null default ''",
+		"dirty boolean not null default false",
+		"duration_ms bigint not null default 0",
+		"hostname varchar(255) not null default ''",
+		"os_user varchar(255) not null default ''",
+		"app_version varchar(255) not null default ''",
+		"direction varchar(10) not null default 'up'",
+		"error text not null default ''",
+		"batch bigint not null default 0",
+		"sequence bigint not null default 0",
+	} {
+		mock.ExpectExec(fmt.Sprintf("ALTER TABLE \"%s\" ADD COLUMN %s", provider.DefaultTableName, column)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(fmt.Sprintf("ALTER TABLE \"%s\" ALTER COLUMN time TYPE timestamptz", provider.DefaultTableName)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(provider.SequenceBackfill(fmt.Sprintf("\"%s\"", provider.DefaultTableName))).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(fmt.Sprintf("DELETE FROM \"%s_meta\"", provider.DefaultTableName)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(fmt.Sprintf("INSERT INTO \"%s_meta\" (id, version) VALUES (1, %d)", provider.DefaultTableName, provider.HistoryVersion)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	p := postgres.NewPsqlProvider(db)
+
+	assert.NoError(t, p.CreateMigrationsTable(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPsqlProvider_TableSettings(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock($1)").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("create table if not exists \"meta\".\"my\"\"history\" ( name varchar(500) not null constraint \"my\"\"history_pk\" primary key, time timestamptz, checksum varchar(255) not null default '', dirty boolean not null default false, duration_ms bigint not null default 0, hostname varchar(255) not null default '', os_user varchar(255) not null default '', app_version varchar(255) not null default '', direction varchar(10) not null default 'up', error text not null default '', batch bigint not null default 0, sequence bigint not null default 0 ); create unique index if not exists \"my\"\"history_name_uindex\" on \"meta\".\"my\"\"history\" (name); create table if not exists \"meta\".\"my\"\"history_meta\" ( id integer not null primary key, version integer not null );").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version FROM \"meta\".\"my\"\"history_meta\" WHERE id = 1").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(provider.HistoryVersion))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence FROM \"meta\".\"my\"\"history\" ORDER BY sequence DESC").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch", "sequence"}))
+
+	p := postgres.NewPsqlProvider(db, provider.WithTableName("my\"history"), provider.WithSchema("meta"))
+
+	assert.NoError(t, p.CreateMigrationsTable(context.Background()))
+
+	_, err = p.GetApplied(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestPsqlProvider_GetDb(t *testing.T) {
 	db, _, err := sqlmock.New()
 	defer db.Close()
@@ -62,35 +147,50 @@ func TestPsqlProvider_GetDb(t *testing.T) {
 }
 
 func TestPsqlProvider_GetApplied(t *testing.T) {
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
 	cases := map[string]struct {
 		execError error
 		execRows  *sqlmock.Rows
 
 		expectQuery  string
 		expectErr    error
-		expectResult []string
+		expectResult []provider.HistoryRecord
 	}{
 		"empty migration table": {
 			execError:    nil,
-			execRows:     sqlmock.NewRows([]string{"name"}),
-			expectQuery:  fmt.Sprintf("SELECT name FROM %s ORDER BY time DESC, name DESC", provider.DefaultTableName),
+			execRows:     sqlmock.NewRows([]string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch", "sequence"}),
+			expectQuery:  fmt.Sprintf("SELECT name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence FROM \"%s\" ORDER BY sequence DESC", provider.DefaultTableName),
 			expectErr:    nil,
-			expectResult: []string{},
+			expectResult: []provider.HistoryRecord{},
 		},
 		"all is ok": {
-			execError:    nil,
-			execRows:     sqlmock.NewRows([]string{"name"}).AddRow("migration_1").AddRow("migration_2"),
-			expectQuery:  fmt.Sprintf("SELECT name FROM %s ORDER BY time DESC, name DESC", provider.DefaultTableName),
-			expectErr:    nil,
-			expectResult: []string{"migration_1", "migration_2"},
+			execError: nil,
+			execRows: sqlmock.NewRows([]string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch", "sequence"}).
+				AddRow("migration_1", now, "abc", false, int64(1500), "host-1", "deploy", "1.2.3", "up", nil, int64(3), int64(2)).
+				AddRow("migration_2", now.Format("2006-01-02 15:04:05"), "", true, int64(0), "", "", "", "down", "some error", nil, int64(1)),
+			expectQuery: fmt.Sprintf("SELECT name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence FROM \"%s\" ORDER BY sequence DESC", provider.DefaultTableName),
+			expectErr:   nil,
+			expectResult: []provider.HistoryRecord{
+				{Name: "migration_1", AppliedAt: now, Checksum: "abc", Duration: 1500 * time.Millisecond, Hostname: "host-1", OSUser: "deploy", AppVersion: "1.2.3", Direction: "up", Batch: 3, Sequence: 2},
+				{Name: "migration_2", AppliedAt: now, Dirty: true, Direction: "down", Error: "some error", Sequence: 1},
+			},
 		},
 		"db error": {
 			execError:    errors.New("some db error"),
-			execRows:     sqlmock.NewRows([]string{"name"}),
-			expectQuery:  fmt.Sprintf("SELECT name FROM %s ORDER BY time DESC, name DESC", provider.DefaultTableName),
+			execRows:     sqlmock.NewRows([]string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch", "sequence"}),
+			expectQuery:  fmt.Sprintf("SELECT name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence FROM \"%s\" ORDER BY sequence DESC", provider.DefaultTableName),
 			expectErr:    errors.New("some db error"),
 			expectResult: nil,
 		},
+		"rows error": {
+			execError: nil,
+			execRows: sqlmock.NewRows([]string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch", "sequence"}).
+				AddRow("migration_1", time.Now(), "", false, int64(0), "", "", "", "up", nil, int64(1), int64(1)).
+				RowError(0, errors.New("some rows error")),
+			expectQuery:  fmt.Sprintf("SELECT name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence FROM \"%s\" ORDER BY sequence DESC", provider.DefaultTableName),
+			expectErr:    errors.New("some rows error"),
+			expectResult: nil,
+		},
 	}
 
 	for name, c := range cases {
@@ -114,29 +214,26 @@ func TestPsqlProvider_GetApplied(t *testing.T) {
 func TestPsqlProvider_MarkApplied(t *testing.T) {
 	now := time.Now()
 	cases := map[string]struct {
-		name string
-		time time.Time
+		record provider.HistoryRecord
 
 		execError error
 
 		expectQuery string
 		expectErr   error
-		expectArgs  []interface{}
+		expectArgs  []driver.Value
 	}{
 		"all is ok": {
-			name:        "migration_1",
-			time:        now,
+			record:      provider.HistoryRecord{Name: "migration_1", AppliedAt: now, Checksum: "abc", Duration: 1500 * time.Millisecond, Hostname: "host-1", OSUser: "deploy", AppVersion: "1.2.3", Direction: "up", Batch: 2},
 			execError:   nil,
-			expectQuery: fmt.Sprintf("INSERT INTO %s (name, time) VALUES ($1, $2)", provider.DefaultTableName),
-			expectArgs:  []interface{}{"migration_1", now},
+			expectQuery: fmt.Sprintf("INSERT INTO \"%s\" (name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, (SELECT COALESCE(MAX(sequence), 0) + 1 FROM \"%s\")) ON CONFLICT (name) DO UPDATE SET time = EXCLUDED.time, checksum = EXCLUDED.checksum, dirty = EXCLUDED.dirty, duration_ms = EXCLUDED.duration_ms, hostname = EXCLUDED.hostname, os_user = EXCLUDED.os_user, app_version = EXCLUDED.app_version, direction = EXCLUDED.direction, error = EXCLUDED.error, batch = EXCLUDED.batch", provider.DefaultTableName, provider.DefaultTableName),
+			expectArgs:  []driver.Value{"migration_1", now, "abc", false, int64(1500), "host-1", "deploy", "1.2.3", "up", "", int64(2)},
 			expectErr:   nil,
 		},
 		"db error": {
-			name:        "migration_2",
-			time:        now,
+			record:      provider.HistoryRecord{Name: "migration_2", AppliedAt: now, Checksum: "abc", Dirty: true, Direction: "down", Error: "boom"},
 			execError:   errors.New("some db error"),
-			expectQuery: fmt.Sprintf("INSERT INTO %s (name, time) VALUES ($1, $2)", provider.DefaultTableName),
-			expectArgs:  []interface{}{"migration_2", now},
+			expectQuery: fmt.Sprintf("INSERT INTO \"%s\" (name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, (SELECT COALESCE(MAX(sequence), 0) + 1 FROM \"%s\")) ON CONFLICT (name) DO UPDATE SET time = EXCLUDED.time, checksum = EXCLUDED.checksum, dirty = EXCLUDED.dirty, duration_ms = EXCLUDED.duration_ms, hostname = EXCLUDED.hostname, os_user = EXCLUDED.os_user, app_version = EXCLUDED.app_version, direction = EXCLUDED.direction, error = EXCLUDED.error, batch = EXCLUDED.batch", provider.DefaultTableName, provider.DefaultTableName),
+			expectArgs:  []driver.Value{"migration_2", now, "abc", true, int64(0), "", "", "", "down", "boom", int64(0)},
 			expectErr:   errors.New("some db error"),
 		},
 	}
@@ -147,13 +244,13 @@ func TestPsqlProvider_MarkApplied(t *testing.T) {
 			assert.NoError(t, err)
 
 			mock.ExpectExec(c.expectQuery).
-				WithArgs(c.expectArgs[0], c.expectArgs[1]).
+				WithArgs(c.expectArgs...).
 				WillReturnResult(sqlmock.NewResult(1, 1)).
 				WillReturnError(c.execError)
 
 			p := postgres.NewPsqlProvider(db)
 
-			err = p.MarkApplied(context.Background(), c.name, c.time)
+			err = p.MarkApplied(context.Background(), c.record)
 
 			assert.Equal(t, c.expectErr, err)
 		})
@@ -174,14 +271,14 @@ func TestPsqlProvider_DeleteApplied(t *testing.T) {
 		"all is ok": {
 			name:        "migration_1",
 			execError:   nil,
-			expectQuery: fmt.Sprintf("DELETE FROM %s WHERE name=$1", provider.DefaultTableName),
+			expectQuery: fmt.Sprintf("DELETE FROM \"%s\" WHERE name=$1", provider.DefaultTableName),
 			expectArgs:  []interface{}{"migration_1"},
 			expectErr:   nil,
 		},
 		"db error": {
 			name:        "migration_2",
 			execError:   errors.New("some db error"),
-			expectQuery: fmt.Sprintf("DELETE FROM %s WHERE name=$1", provider.DefaultTableName),
+			expectQuery: fmt.Sprintf("DELETE FROM \"%s\" WHERE name=$1", provider.DefaultTableName),
 			expectArgs:  []interface{}{"migration_2"},
 			expectErr:   errors.New("some db error"),
 		},
@@ -205,3 +302,85 @@ func TestPsqlProvider_DeleteApplied(t *testing.T) {
 		})
 	}
 }
+
+func TestPsqlProvider_MarkAppliedTx(t *testing.T) {
+	now := time.Now()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(fmt.Sprintf("INSERT INTO \"%s\" (name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, (SELECT COALESCE(MAX(sequence), 0) + 1 FROM \"%s\")) ON CONFLICT (name) DO UPDATE SET time = EXCLUDED.time, checksum = EXCLUDED.checksum, dirty = EXCLUDED.dirty, duration_ms = EXCLUDED.duration_ms, hostname = EXCLUDED.hostname, os_user = EXCLUDED.os_user, app_version = EXCLUDED.app_version, direction = EXCLUDED.direction, error = EXCLUDED.error, batch = EXCLUDED.batch", provider.DefaultTableName, provider.DefaultTableName)).
+		WithArgs("migration_1", now, "abc", false, int64(0), "", "", "", "", "", int64(0)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+
+	p := postgres.NewPsqlProvider(db)
+	err = p.MarkAppliedTx(context.Background(), tx, provider.HistoryRecord{Name: "migration_1", AppliedAt: now, Checksum: "abc"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, tx.Commit())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPsqlProvider_DeleteAppliedTx(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(fmt.Sprintf("DELETE FROM \"%s\" WHERE name=$1", provider.DefaultTableName)).
+		WithArgs("migration_1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+
+	p := postgres.NewPsqlProvider(db)
+	err = p.DeleteAppliedTx(context.Background(), tx, "migration_1")
+	assert.NoError(t, err)
+
+	assert.NoError(t, tx.Commit())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPsqlProvider_LockUnlock(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectExec("SELECT pg_advisory_lock($1)").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SELECT pg_advisory_unlock($1)").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	p := postgres.NewPsqlProvider(db)
+
+	assert.NoError(t, p.Lock(context.Background()))
+	assert.Error(t, p.Lock(context.Background()), "lock can't be acquired twice")
+	assert.NoError(t, p.Unlock(context.Background()))
+	assert.Error(t, p.Unlock(context.Background()), "lock can't be released twice")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPsqlProvider_LockError(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectExec("SELECT pg_advisory_lock($1)").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnError(errors.New("canceling statement due to user request"))
+
+	p := postgres.NewPsqlProvider(db)
+
+	assert.EqualError(t, p.Lock(context.Background()), "canceling statement due to user request")
+	assert.Error(t, p.Unlock(context.Background()))
+}
@@ -0,0 +1,165 @@
+package provider
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// HistoryRecord - a row of the migration history table
+type HistoryRecord struct {
+	// Name of the applied migration
+	Name string
+	// AppliedAt - time when the migration was applied
+	AppliedAt time.Time
+	// Checksum of the migration at the moment it was applied. It is empty if the migration has no checksum
+	Checksum string
+	// Dirty - the migration was started but hasn't finished, so the database is in an unknown state
+	Dirty bool
+	// Duration - how long the migration was running
+	Duration time.Duration
+	// Hostname - name of the host the migration was run on
+	Hostname string
+	// OSUser - name of the OS user the migration was run by
+	OSUser string
+	// AppVersion - version of the application that ran the migration. It is set by the caller
+	AppVersion string
+	// Direction - "up" for applied migrations or "down" for migrations that are being reverted
+	Direction string
+	// Error - text of the error the dirty migration failed with
+	Error string
+	// Batch - number of the Apply run the migration was applied by. It is 0 for migrations marked as applied by hand
+	// and for migrations applied before batches were recorded
+	Batch int64
+	// Sequence - number of the record in the order of applying. The provider assigns it when the record is inserted
+	// and keeps it when the record is overwritten, so it doesn't depend on clocks of hosts
+	Sequence int64
+}
+
+// MaxNameLength - length of the name column of the migration history table
+const MaxNameLength = 500
+
+// HistoryColumns - columns of the migration history table in the order of RecordArgs and ScanRecord
+var HistoryColumns = []string{
+	"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch",
+}
+
+// SequenceColumn - column of the migration history table holding HistoryRecord.Sequence.
+// It isn't in HistoryColumns because its value is computed by the database
+const SequenceColumn = "sequence"
+
+// HistoryColumnList - function returning comma separated list of HistoryColumns
+func HistoryColumnList() string {
+	return strings.Join(HistoryColumns, ", ")
+}
+
+// HistorySelectList - function returning comma separated list of HistoryColumns and SequenceColumn
+// in the order of ScanRecord
+func HistorySelectList() string {
+	return HistoryColumnList() + ", " + SequenceColumn
+}
+
+// RecordArgs - function returning query args of the record in the order of HistoryColumns
+func RecordArgs(record HistoryRecord) []interface{} {
+	return []interface{}{
+		record.Name,
+		record.AppliedAt,
+		record.Checksum,
+		record.Dirty,
+		record.Duration.Milliseconds(),
+		record.Hostname,
+		record.OSUser,
+		record.AppVersion,
+		record.Direction,
+		record.Error,
+		record.Batch,
+	}
+}
+
+// Scanner - interface of *sql.Row and *sql.Rows
+type Scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// ScanRecord - function scanning a row of HistorySelectList into a record. Time is returned in UTC
+func ScanRecord(row Scanner) (HistoryRecord, error) {
+	var record HistoryRecord
+	var appliedAt Time
+	var durationMs, batch sql.NullInt64
+	var hostname, osUser, appVersion, direction, errText sql.NullString
+
+	err := row.Scan(
+		&record.Name,
+		&appliedAt,
+		&record.Checksum,
+		&record.Dirty,
+		&durationMs,
+		&hostname,
+		&osUser,
+		&appVersion,
+		&direction,
+		&errText,
+		&batch,
+		&record.Sequence,
+	)
+	if err != nil {
+		return HistoryRecord{}, err
+	}
+
+	record.AppliedAt = appliedAt.Time.UTC()
+	record.Duration = time.Duration(durationMs.Int64) * time.Millisecond
+	record.Hostname = hostname.String
+	record.OSUser = osUser.String
+	record.AppVersion = appVersion.String
+	record.Direction = direction.String
+	record.Error = errText.String
+	record.Batch = batch.Int64
+
+	return record, nil
+}
+
+// Time - sql.Scanner reading time from drivers returning time.Time as well as from drivers returning text
+// (e.g. mysql driver without parseTime option)
+type Time struct {
+	time.Time
+}
+
+// timeLayouts - layouts of time returned as text by different drivers
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+// Scan - function implementing sql.Scanner
+func (t *Time) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		t.Time = time.Time{}
+		return nil
+	case time.Time:
+		t.Time = v
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	}
+
+	return fmt.Errorf("can't scan %T into time", value)
+}
+
+func (t *Time) parse(value string) error {
+	for _, layout := range timeLayouts {
+		parsed, err := time.Parse(layout, value)
+		if err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+
+	return fmt.Errorf("can't parse time '%s'", value)
+}
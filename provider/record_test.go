@@ -0,0 +1,42 @@
+package provider_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iamsalnikov/mymigrate/provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTime_Scan(t *testing.T) {
+	expected := time.Date(2020, 10, 17, 5, 40, 49, 0, time.UTC)
+
+	cases := map[string]struct {
+		value     interface{}
+		expectErr bool
+		expected  time.Time
+	}{
+		"nil":             {value: nil, expected: time.Time{}},
+		"time":            {value: expected, expected: expected},
+		"mysql text":      {value: []byte("2020-10-17 05:40:49"), expected: expected},
+		"sqlite text":     {value: "2020-10-17 05:40:49+00:00", expected: expected},
+		"rfc3339 text":    {value: "2020-10-17T05:40:49Z", expected: expected},
+		"fractional text": {value: "2020-10-17 05:40:49.000000", expected: expected},
+		"garbage":         {value: "yesterday", expectErr: true},
+		"unsupported":     {value: 42, expectErr: true},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var scanned provider.Time
+			err := scanned.Scan(c.value)
+			if c.expectErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, c.expected.Equal(scanned.Time), "expected %s but got %s", c.expected, scanned.Time)
+		})
+	}
+}
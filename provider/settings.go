@@ -1,4 +1,93 @@
 package provider
 
+import (
+	"strings"
+	"time"
+)
+
 // DefaultTableName - table name for migration history
 const DefaultTableName = "mymigration"
+
+// DefaultLockPollInterval - how often a provider emulating the migration lock with a table checks whether it is released
+const DefaultLockPollInterval = 100 * time.Millisecond
+
+// Settings - settings of the migration history table
+type Settings struct {
+	// TableName - name of the migration history table
+	TableName string
+	// Schema - schema (postgres, sqlite) or database (mysql) of the migration history table.
+	// Empty schema means the default one of the connection
+	Schema string
+	// LockPollInterval - how often a provider emulating the migration lock with a table (sqlite)
+	// checks whether the lock is released
+	LockPollInterval time.Duration
+	// LockStaleTimeout - age of the migration lock emulated with a table (sqlite) after which its owner
+	// is considered gone and the lock is taken over. Zero means the lock is never taken over
+	LockStaleTimeout time.Duration
+}
+
+// Option - function changing Settings of a provider
+type Option func(s *Settings)
+
+// WithTableName - option setting name of the migration history table
+func WithTableName(name string) Option {
+	return func(s *Settings) {
+		s.TableName = name
+	}
+}
+
+// WithSchema - option setting schema (postgres, sqlite) or database (mysql) of the migration history table
+func WithSchema(schema string) Option {
+	return func(s *Settings) {
+		s.Schema = schema
+	}
+}
+
+// WithLockPollInterval - option setting how often the migration lock emulated with a table is checked
+func WithLockPollInterval(interval time.Duration) Option {
+	return func(s *Settings) {
+		s.LockPollInterval = interval
+	}
+}
+
+// WithLockStaleTimeout - option setting age of the migration lock emulated with a table after which it is taken over.
+// A lock of a crashed process is never released, but a long migration holds the lock for long too,
+// so the timeout should be longer than the longest migration run
+func WithLockStaleTimeout(timeout time.Duration) Option {
+	return func(s *Settings) {
+		s.LockStaleTimeout = timeout
+	}
+}
+
+// NewSettings - constructor for Settings with DefaultTableName and DefaultLockPollInterval changed by opts
+func NewSettings(opts ...Option) Settings {
+	s := Settings{TableName: DefaultTableName, LockPollInterval: DefaultLockPollInterval}
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	return s
+}
+
+// FullName - function returning unquoted name of the migration history table with the schema
+func (s Settings) FullName() string {
+	if len(s.Schema) == 0 {
+		return s.TableName
+	}
+
+	return s.Schema + "." + s.TableName
+}
+
+// QualifiedName - function returning name of the table from the settings schema quoted with quote
+func (s Settings) QualifiedName(name, quote string) string {
+	if len(s.Schema) == 0 {
+		return QuoteIdent(name, quote)
+	}
+
+	return QuoteIdent(s.Schema, quote) + "." + QuoteIdent(name, quote)
+}
+
+// QuoteIdent - function quoting identifier with quote. Quotes inside the identifier are doubled
+func QuoteIdent(name, quote string) string {
+	return quote + strings.ReplaceAll(name, quote, quote+quote) + quote
+}
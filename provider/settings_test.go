@@ -0,0 +1,53 @@
+package provider_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iamsalnikov/mymigrate/provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSettings(t *testing.T) {
+	cases := map[string]struct {
+		opts []provider.Option
+
+		expectSettings  provider.Settings
+		expectFullName  string
+		expectQualified string
+	}{
+		"defaults": {
+			expectSettings:  provider.Settings{TableName: provider.DefaultTableName, LockPollInterval: provider.DefaultLockPollInterval},
+			expectFullName:  "mymigration",
+			expectQualified: `"mymigration"`,
+		},
+		"table name and schema": {
+			opts:            []provider.Option{provider.WithTableName("history"), provider.WithSchema("meta")},
+			expectSettings:  provider.Settings{TableName: "history", Schema: "meta", LockPollInterval: provider.DefaultLockPollInterval},
+			expectFullName:  "meta.history",
+			expectQualified: `"meta"."history"`,
+		},
+		"quotes inside names": {
+			opts:            []provider.Option{provider.WithTableName(`my"table`)},
+			expectSettings:  provider.Settings{TableName: `my"table`, LockPollInterval: provider.DefaultLockPollInterval},
+			expectFullName:  `my"table`,
+			expectQualified: `"my""table"`,
+		},
+		"lock waiting": {
+			opts:            []provider.Option{provider.WithLockPollInterval(time.Second), provider.WithLockStaleTimeout(time.Hour)},
+			expectSettings:  provider.Settings{TableName: provider.DefaultTableName, LockPollInterval: time.Second, LockStaleTimeout: time.Hour},
+			expectFullName:  "mymigration",
+			expectQualified: `"mymigration"`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := provider.NewSettings(c.opts...)
+
+			assert.Equal(t, c.expectSettings, s)
+			assert.Equal(t, c.expectFullName, s.FullName())
+			assert.Equal(t, c.expectQualified, s.QualifiedName(s.TableName, `"`))
+		})
+	}
+}
@@ -3,19 +3,29 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/iamsalnikov/mymigrate/provider"
+	"os"
+	"strings"
 	"time"
 )
 
 // Provider - migration provider for sqlite db
 type Provider struct {
-	db *sql.DB
+	db       *sql.DB
+	settings provider.Settings
 }
 
-// NewSqliteProvider - constructor for sqlite Provider
-func NewSqliteProvider(db *sql.DB) *Provider {
-	return &Provider{db: db}
+// NewSqliteProvider - constructor for sqlite Provider.
+// Use provider.WithTableName and provider.WithSchema (a name of an attached database) options
+// to change the migration history table, provider.WithLockPollInterval and provider.WithLockStaleTimeout
+// to change waiting for the migration lock
+func NewSqliteProvider(db *sql.DB, opts ...provider.Option) *Provider {
+	return &Provider{
+		db:       db,
+		settings: provider.NewSettings(opts...),
+	}
 }
 
 // GetDb - function returning internal db object
@@ -23,49 +33,295 @@ func (p *Provider) GetDb() *sql.DB {
 	return p.db
 }
 
-// CreateMigrationsTable - function creating migration table in db
-func (p *Provider) CreateMigrationsTable() error {
+// CreateMigrationsTable - function creating migration table in db.
+// A table of an older layout is upgraded in place inside a transaction
+func (p *Provider) CreateMigrationsTable(ctx context.Context) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = p.createMigrationsTable(ctx, tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p *Provider) createMigrationsTable(ctx context.Context, tx *sql.Tx) error {
 	query := fmt.Sprintf(`create table if not exists %s
 			(
 				name varchar(500) not null constraint table_name_pk primary key,
-				time timestamp
+				time timestamp,
+				checksum varchar(255) not null default '',
+				dirty boolean not null default 0,
+				duration_ms bigint not null default 0,
+				hostname varchar(255) not null default '',
+				os_user varchar(255) not null default '',
+				app_version varchar(255) not null default '',
+				direction varchar(10) not null default 'up',
+				error text not null default '',
+				batch bigint not null default 0,
+				sequence bigint not null default 0
 			);
-		create unique index if not exists %s_name_uindex on %s (name);`, provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName)
+		create unique index if not exists %s on %s (name);
+		create table if not exists %s
+			(
+				id integer not null primary key,
+				version integer not null
+			);`, p.table(), p.settings.QualifiedName(p.settings.TableName+"_name_uindex", `"`), provider.QuoteIdent(p.settings.TableName, `"`), p.metaTable())
 
-	_, err := p.db.Exec(query)
-	return err
+	_, err := tx.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return p.upgrade().Run(ctx, tx)
+}
+
+// upgrade returns upgrade of the migration history table to the current layout
+func (p *Provider) upgrade() provider.Upgrade {
+	return provider.Upgrade{
+		Table:     p.table(),
+		MetaTable: p.metaTable(),
+		ColumnDefinitions: map[string]string{
+			"checksum":    "varchar(255) not null default ''",
+			"dirty":       "boolean not null default 0",
+			"duration_ms": "bigint not null default 0",
+			"hostname":    "varchar(255) not null default ''",
+			"os_user":     "varchar(255) not null default ''",
+			"app_version": "varchar(255) not null default ''",
+			"direction":   "varchar(10) not null default 'up'",
+			"error":       "text not null default ''",
+			"batch":       "bigint not null default 0",
+			"sequence":    "bigint not null default 0",
+		},
+		SequenceStatements: []string{
+			provider.SequenceBackfill(p.table()),
+		},
+	}
 }
 
-// GetApplied - function returning list applied migrations
-func (p *Provider) GetApplied(ctx context.Context) ([]string, error) {
-	query := fmt.Sprintf("SELECT name FROM %s ORDER BY time DESC, name DESC", provider.DefaultTableName)
+// GetApplied - function returning list applied migrations from the latest applied to the earliest one
+func (p *Provider) GetApplied(ctx context.Context) ([]provider.HistoryRecord, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s ORDER BY sequence DESC", provider.HistorySelectList(), p.table())
 	rows, err := p.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
-	res := make([]string, 0)
+	defer rows.Close()
+
+	res := make([]provider.HistoryRecord, 0)
 	for rows.Next() {
-		var name string
-		err := rows.Scan(&name)
+		record, err := provider.ScanRecord(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		res = append(res, name)
+		res = append(res, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
+
 	return res, nil
 }
 
-// MarkApplied - function for mark migration applied
-func (p *Provider) MarkApplied(ctx context.Context, name string, t time.Time) error {
-	query := fmt.Sprintf("INSERT INTO %s (name, time) VALUES (?, ?)", provider.DefaultTableName)
-	_, err := p.db.ExecContext(ctx, query, name, t)
+// MarkApplied - function for mark migration applied.
+// It overwrites the row of the migration if there is one, so it is used to flip a dirty row to applied and back
+func (p *Provider) MarkApplied(ctx context.Context, record provider.HistoryRecord) error {
+	_, err := p.db.ExecContext(ctx, p.markAppliedQuery(), provider.RecordArgs(record)...)
+	return err
+}
+
+// MarkAppliedTx - function for mark migration applied inside a transaction
+func (p *Provider) MarkAppliedTx(ctx context.Context, tx *sql.Tx, record provider.HistoryRecord) error {
+	_, err := tx.ExecContext(ctx, p.markAppliedQuery(), provider.RecordArgs(record)...)
 	return err
 }
 
+func (p *Provider) markAppliedQuery() string {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(provider.HistoryColumns)), ", ")
+	updates := make([]string, 0, len(provider.HistoryColumns))
+	for _, column := range provider.HistoryColumns {
+		if column != "name" {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", column, column))
+		}
+	}
+
+	// the sequence of a new row follows the latest one and isn't changed when the row is overwritten
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s, (SELECT COALESCE(MAX(sequence), 0) + 1 FROM %s)) ON CONFLICT (name) DO UPDATE SET %s",
+		p.table(), provider.HistorySelectList(), placeholders, p.table(), strings.Join(updates, ", "))
+}
+
 // DeleteApplied - function for delete migration from applied list
 func (p *Provider) DeleteApplied(ctx context.Context, name string) error {
-	query := fmt.Sprintf("DELETE FROM %s WHERE name=?", provider.DefaultTableName)
-	_, err := p.db.ExecContext(ctx, query, name)
+	_, err := p.db.ExecContext(ctx, p.deleteAppliedQuery(), name)
+	return err
+}
+
+// DeleteAppliedTx - function for delete migration from applied list inside a transaction
+func (p *Provider) DeleteAppliedTx(ctx context.Context, tx *sql.Tx, name string) error {
+	_, err := tx.ExecContext(ctx, p.deleteAppliedQuery(), name)
 	return err
 }
+
+func (p *Provider) deleteAppliedQuery() string {
+	return fmt.Sprintf("DELETE FROM %s WHERE name=?", p.table())
+}
+
+// Lock - function acquiring the migration lock.
+// SQLite has no advisory locks, so the lock is a row in a separate lock table with the owner and the time of acquiring.
+// It checks the lock every provider.WithLockPollInterval and waits until the row is deleted by another process,
+// the lock gets older than provider.WithLockStaleTimeout or ctx is done
+func (p *Provider) Lock(ctx context.Context) error {
+	err := p.createLockTable(ctx)
+	if err != nil {
+		return err
+	}
+
+	owner := lockOwner()
+	query := fmt.Sprintf("INSERT OR IGNORE INTO %s (id, time, owner) VALUES (1, ?, ?)", p.lockTableName())
+	for {
+		res, err := p.db.ExecContext(ctx, query, time.Now().UTC(), owner)
+		if err != nil {
+			return err
+		}
+
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if inserted == 1 {
+			return nil
+		}
+
+		holder, since, err := p.lockHolder(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if p.settings.LockStaleTimeout > 0 && time.Since(since) > p.settings.LockStaleTimeout {
+			// the owner is compared, so a lock just taken over by another process isn't deleted
+			query := fmt.Sprintf("DELETE FROM %s WHERE id=1 AND owner=?", p.lockTableName())
+			_, err = p.db.ExecContext(ctx, query, holder)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("lock in %s is held by '%s' since %s, delete the row if the process is gone: %w",
+				p.lockTableName(), holder, since.UTC().Format(time.RFC3339), ctx.Err())
+		case <-time.After(p.settings.LockPollInterval):
+		}
+	}
+}
+
+// createLockTable creates the table holding the migration lock.
+// A table created by an older release has no owner column, and the column is added
+func (p *Provider) createLockTable(ctx context.Context) error {
+	query := fmt.Sprintf(`create table if not exists %s
+		(
+			id integer not null primary key check (id = 1),
+			time timestamp,
+			owner varchar(255) not null default ''
+		);`, p.lockTableName())
+
+	_, err := p.db.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	hasOwner, err := p.lockTableHasOwner(ctx)
+	if err != nil || hasOwner {
+		return err
+	}
+
+	_, err = p.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN owner varchar(255) not null default ''", p.lockTableName()))
+	return err
+}
+
+// lockTableHasOwner tells whether the table holding the migration lock has the owner column
+func (p *Provider) lockTableHasOwner(ctx context.Context) (bool, error) {
+	rows, err := p.db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", p.lockTableName()))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return false, err
+	}
+
+	for _, column := range columns {
+		if strings.EqualFold(column, "owner") {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// lockHolder returns the owner of the migration lock and the time it was acquired at
+func (p *Provider) lockHolder(ctx context.Context) (string, time.Time, error) {
+	var owner string
+	var since time.Time
+	query := fmt.Sprintf("SELECT owner, time FROM %s WHERE id=1", p.lockTableName())
+	err := p.db.QueryRowContext(ctx, query).Scan(&owner, &since)
+
+	return owner, since, err
+}
+
+// lockOwner returns the host and the process id of the current process as the owner of the migration lock
+func lockOwner() string {
+	hostname, _ := os.Hostname()
+
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}
+
+// Unlock - function releasing the migration lock acquired by Lock.
+// Only the row of the current process is deleted, and an error is returned if the lock was taken over as stale
+func (p *Provider) Unlock(ctx context.Context) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id=1 AND owner=?", p.lockTableName())
+	res, err := p.db.ExecContext(ctx, query, lockOwner())
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return fmt.Errorf("lock in %s isn't held by '%s', it was taken over by another process", p.lockTableName(), lockOwner())
+	}
+
+	return nil
+}
+
+// lockTableName returns quoted name of the table holding the migration lock
+func (p *Provider) lockTableName() string {
+	return p.settings.QualifiedName(p.settings.TableName+"_lock", `"`)
+}
+
+// metaTable returns quoted name of the table holding version of the migration history table
+func (p *Provider) metaTable() string {
+	return p.settings.QualifiedName(p.settings.TableName+"_meta", `"`)
+}
+
+// table returns quoted name of the migration history table
+func (p *Provider) table() string {
+	return p.settings.QualifiedName(p.settings.TableName, `"`)
+}
@@ -2,12 +2,14 @@ package sqlite_test
 
 import (
 	"context"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/iamsalnikov/mymigrate/provider"
 	"github.com/iamsalnikov/mymigrate/provider/sqlite"
 	"github.com/stretchr/testify/assert"
+	"os"
 	"testing"
 	"time"
 )
@@ -21,12 +23,12 @@ func TestSqliteProvider_CreateMigrationsTable(t *testing.T) {
 	}{
 		"All is ok": {
 			execError:   nil,
-			expectQuery: fmt.Sprintf("create table if not exists %s ( name varchar(500) not null constraint table_name_pk primary key, time timestamp ); create unique index if not exists %s_name_uindex on %s (name);", provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName),
+			expectQuery: fmt.Sprintf("create table if not exists \"%s\" ( name varchar(500) not null constraint table_name_pk primary key, time timestamp, checksum varchar(255) not null default '', dirty boolean not null default 0, duration_ms bigint not null default 0, hostname varchar(255) not null default '', os_user varchar(255) not null default '', app_version varchar(255) not null default '', direction varchar(10) not null default 'up', error text not null default '', batch bigint not null default 0, sequence bigint not null default 0 ); create unique index if not exists \"%s_name_uindex\" on \"%s\" (name); create table if not exists \"%s_meta\" ( id integer not null primary key, version integer not null );", provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName),
 			expectErr:   nil,
 		},
 		"db error": {
 			execError:   errors.New("some db error"),
-			expectQuery: fmt.Sprintf("create table if not exists %s ( name varchar(500) not null constraint table_name_pk primary key, time timestamp ); create unique index if not exists %s_name_uindex on %s (name);", provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName),
+			expectQuery: fmt.Sprintf("create table if not exists \"%s\" ( name varchar(500) not null constraint table_name_pk primary key, time timestamp, checksum varchar(255) not null default '', dirty boolean not null default 0, duration_ms bigint not null default 0, hostname varchar(255) not null default '', os_user varchar(255) not null default '', app_version varchar(255) not null default '', direction varchar(10) not null default 'up', error text not null default '', batch bigint not null default 0, sequence bigint not null default 0 ); create unique index if not exists \"%s_name_uindex\" on \"%s\" (name); create table if not exists \"%s_meta\" ( id integer not null primary key, version integer not null );", provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName),
 			expectErr:   errors.New("some db error"),
 		},
 	}
@@ -37,19 +39,92 @@ func TestSqliteProvider_CreateMigrationsTable(t *testing.T) {
 			defer db.Close()
 			assert.NoError(t, err)
 
+			mock.ExpectBegin()
 			mock.ExpectExec(c.expectQuery).
 				WillReturnResult(sqlmock.NewResult(1, 1)).
 				WillReturnError(c.execError)
+			if c.execError == nil {
+				mock.ExpectQuery(fmt.Sprintf("SELECT version FROM \"%s_meta\" WHERE id = 1", provider.DefaultTableName)).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(provider.HistoryVersion))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
 
 			p := sqlite.NewSqliteProvider(db)
-			err = p.CreateMigrationsTable()
+			err = p.CreateMigrationsTable(context.Background())
 
 			assert.Equal(t, c.expectErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 
 }
 
+func TestSqliteProvider_CreateMigrationsTableUpgrade(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(fmt.Sprintf("create table if not exists \"%s\" ( name varchar(500) not null constraint table_name_pk primary key, time timestamp, checksum varchar(255) not null default '', dirty boolean not null default 0, duration_ms bigint not null default 0, hostname varchar(255) not null default '', os_user varchar(255) not null default '', app_version varchar(255) not null default '', direction varchar(10) not null default 'up', error text not null default '', batch bigint not null default 0, sequence bigint not null default 0 ); create unique index if not exists \"%s_name_uindex\" on \"%s\" (name); create table if not exists \"%s_meta\" ( id integer not null primary key, version integer not null );", provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName, provider.DefaultTableName)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(fmt.Sprintf("SELECT version FROM \"%s_meta\" WHERE id = 1", provider.DefaultTableName)).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectQuery(fmt.Sprintf("SELECT * FROM \"%s\" WHERE 1 = 0", provider.DefaultTableName)).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "time"}))
+	for _, column := range []string{
+		"checksum varchar(255) not null default ''",
+		"dirty boolean not null default 0",
+		"duration_ms bigint not null default 0",
+		"hostname varchar(255) not null default ''",
+		"os_user varchar(255) not null default ''",
+		"app_version varchar(255) not null default ''",
+		"direction varchar(10) not null default 'up'",
+		"error text not null default ''",
+		"batch bigint not null default 0",
+		"sequence bigint not null default 0",
+	} {
+		mock.ExpectExec(fmt.Sprintf("ALTER TABLE \"%s\" ADD COLUMN %s", provider.DefaultTableName, column)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(provider.SequenceBackfill(fmt.Sprintf("\"%s\"", provider.DefaultTableName))).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(fmt.Sprintf("DELETE FROM \"%s_meta\"", provider.DefaultTableName)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(fmt.Sprintf("INSERT INTO \"%s_meta\" (id, version) VALUES (1, %d)", provider.DefaultTableName, provider.HistoryVersion)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	p := sqlite.NewSqliteProvider(db)
+
+	assert.NoError(t, p.CreateMigrationsTable(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSqliteProvider_TableSettings(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("create table if not exists \"meta\".\"my\"\"history\" ( name varchar(500) not null constraint table_name_pk primary key, time timestamp, checksum varchar(255) not null default '', dirty boolean not null default 0, duration_ms bigint not null default 0, hostname varchar(255) not null default '', os_user varchar(255) not null default '', app_version varchar(255) not null default '', direction varchar(10) not null default 'up', error text not null default '', batch bigint not null default 0, sequence bigint not null default 0 ); create unique index if not exists \"meta\".\"my\"\"history_name_uindex\" on \"my\"\"history\" (name); create table if not exists \"meta\".\"my\"\"history_meta\" ( id integer not null primary key, version integer not null );").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version FROM \"meta\".\"my\"\"history_meta\" WHERE id = 1").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(provider.HistoryVersion))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence FROM \"meta\".\"my\"\"history\" ORDER BY sequence DESC").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch", "sequence"}))
+
+	p := sqlite.NewSqliteProvider(db, provider.WithTableName("my\"history"), provider.WithSchema("meta"))
+
+	assert.NoError(t, p.CreateMigrationsTable(context.Background()))
+
+	_, err = p.GetApplied(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestSqliteProvider_GetDb(t *testing.T) {
 	db, _, err := sqlmock.New()
 	defer db.Close()
@@ -61,35 +136,50 @@ func TestSqliteProvider_GetDb(t *testing.T) {
 }
 
 func TestSqliteProvider_GetApplied(t *testing.T) {
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
 	cases := map[string]struct {
 		execError error
 		execRows  *sqlmock.Rows
 
 		expectQuery  string
 		expectErr    error
-		expectResult []string
+		expectResult []provider.HistoryRecord
 	}{
 		"empty migration table": {
 			execError:    nil,
-			execRows:     sqlmock.NewRows([]string{"name"}),
-			expectQuery:  fmt.Sprintf("SELECT name FROM %s ORDER BY time DESC, name DESC", provider.DefaultTableName),
+			execRows:     sqlmock.NewRows([]string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch", "sequence"}),
+			expectQuery:  fmt.Sprintf("SELECT name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence FROM \"%s\" ORDER BY sequence DESC", provider.DefaultTableName),
 			expectErr:    nil,
-			expectResult: []string{},
+			expectResult: []provider.HistoryRecord{},
 		},
 		"all is ok": {
-			execError:    nil,
-			execRows:     sqlmock.NewRows([]string{"name"}).AddRow("migration_1").AddRow("migration_2"),
-			expectQuery:  fmt.Sprintf("SELECT name FROM %s ORDER BY time DESC, name DESC", provider.DefaultTableName),
-			expectErr:    nil,
-			expectResult: []string{"migration_1", "migration_2"},
+			execError: nil,
+			execRows: sqlmock.NewRows([]string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch", "sequence"}).
+				AddRow("migration_1", now, "abc", false, int64(1500), "host-1", "deploy", "1.2.3", "up", nil, int64(3), int64(2)).
+				AddRow("migration_2", now.Format("2006-01-02 15:04:05"), "", true, int64(0), "", "", "", "down", "some error", nil, int64(1)),
+			expectQuery: fmt.Sprintf("SELECT name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence FROM \"%s\" ORDER BY sequence DESC", provider.DefaultTableName),
+			expectErr:   nil,
+			expectResult: []provider.HistoryRecord{
+				{Name: "migration_1", AppliedAt: now, Checksum: "abc", Duration: 1500 * time.Millisecond, Hostname: "host-1", OSUser: "deploy", AppVersion: "1.2.3", Direction: "up", Batch: 3, Sequence: 2},
+				{Name: "migration_2", AppliedAt: now, Dirty: true, Direction: "down", Error: "some error", Sequence: 1},
+			},
 		},
 		"db error": {
 			execError:    errors.New("some db error"),
-			execRows:     sqlmock.NewRows([]string{"name"}),
-			expectQuery:  fmt.Sprintf("SELECT name FROM %s ORDER BY time DESC, name DESC", provider.DefaultTableName),
+			execRows:     sqlmock.NewRows([]string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch", "sequence"}),
+			expectQuery:  fmt.Sprintf("SELECT name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence FROM \"%s\" ORDER BY sequence DESC", provider.DefaultTableName),
 			expectErr:    errors.New("some db error"),
 			expectResult: nil,
 		},
+		"rows error": {
+			execError: nil,
+			execRows: sqlmock.NewRows([]string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error", "batch", "sequence"}).
+				AddRow("migration_1", time.Now(), "", false, int64(0), "", "", "", "up", nil, int64(1), int64(1)).
+				RowError(0, errors.New("some rows error")),
+			expectQuery:  fmt.Sprintf("SELECT name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence FROM \"%s\" ORDER BY sequence DESC", provider.DefaultTableName),
+			expectErr:    errors.New("some rows error"),
+			expectResult: nil,
+		},
 	}
 
 	for name, c := range cases {
@@ -113,29 +203,26 @@ func TestSqliteProvider_GetApplied(t *testing.T) {
 func TestSqliteProvider_MarkApplied(t *testing.T) {
 	now := time.Now()
 	cases := map[string]struct {
-		name string
-		time time.Time
+		record provider.HistoryRecord
 
 		execError error
 
 		expectQuery string
 		expectErr   error
-		expectArgs  []interface{}
+		expectArgs  []driver.Value
 	}{
 		"all is ok": {
-			name:        "migration_1",
-			time:        now,
+			record:      provider.HistoryRecord{Name: "migration_1", AppliedAt: now, Checksum: "abc", Duration: 1500 * time.Millisecond, Hostname: "host-1", OSUser: "deploy", AppVersion: "1.2.3", Direction: "up", Batch: 2},
 			execError:   nil,
-			expectQuery: fmt.Sprintf("INSERT INTO %s (name, time) VALUES (?, ?)", provider.DefaultTableName),
-			expectArgs:  []interface{}{"migration_1", now},
+			expectQuery: fmt.Sprintf("INSERT INTO \"%s\" (name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, (SELECT COALESCE(MAX(sequence), 0) + 1 FROM \"%s\")) ON CONFLICT (name) DO UPDATE SET time = excluded.time, checksum = excluded.checksum, dirty = excluded.dirty, duration_ms = excluded.duration_ms, hostname = excluded.hostname, os_user = excluded.os_user, app_version = excluded.app_version, direction = excluded.direction, error = excluded.error, batch = excluded.batch", provider.DefaultTableName, provider.DefaultTableName),
+			expectArgs:  []driver.Value{"migration_1", now, "abc", false, int64(1500), "host-1", "deploy", "1.2.3", "up", "", int64(2)},
 			expectErr:   nil,
 		},
 		"db error": {
-			name:        "migration_2",
-			time:        now,
+			record:      provider.HistoryRecord{Name: "migration_2", AppliedAt: now, Checksum: "abc", Dirty: true, Direction: "down", Error: "boom"},
 			execError:   errors.New("some db error"),
-			expectQuery: fmt.Sprintf("INSERT INTO %s (name, time) VALUES (?, ?)", provider.DefaultTableName),
-			expectArgs:  []interface{}{"migration_2", now},
+			expectQuery: fmt.Sprintf("INSERT INTO \"%s\" (name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, (SELECT COALESCE(MAX(sequence), 0) + 1 FROM \"%s\")) ON CONFLICT (name) DO UPDATE SET time = excluded.time, checksum = excluded.checksum, dirty = excluded.dirty, duration_ms = excluded.duration_ms, hostname = excluded.hostname, os_user = excluded.os_user, app_version = excluded.app_version, direction = excluded.direction, error = excluded.error, batch = excluded.batch", provider.DefaultTableName, provider.DefaultTableName),
+			expectArgs:  []driver.Value{"migration_2", now, "abc", true, int64(0), "", "", "", "down", "boom", int64(0)},
 			expectErr:   errors.New("some db error"),
 		},
 	}
@@ -146,13 +233,13 @@ func TestSqliteProvider_MarkApplied(t *testing.T) {
 			assert.NoError(t, err)
 
 			mock.ExpectExec(c.expectQuery).
-				WithArgs(c.expectArgs[0], c.expectArgs[1]).
+				WithArgs(c.expectArgs...).
 				WillReturnResult(sqlmock.NewResult(1, 1)).
 				WillReturnError(c.execError)
 
 			p := sqlite.NewSqliteProvider(db)
 
-			err = p.MarkApplied(context.Background(), c.name, c.time)
+			err = p.MarkApplied(context.Background(), c.record)
 
 			assert.Equal(t, c.expectErr, err)
 		})
@@ -173,14 +260,14 @@ func TestSqliteProvider_DeleteApplied(t *testing.T) {
 		"all is ok": {
 			name:        "migration_1",
 			execError:   nil,
-			expectQuery: fmt.Sprintf("DELETE FROM %s WHERE name=?", provider.DefaultTableName),
+			expectQuery: fmt.Sprintf("DELETE FROM \"%s\" WHERE name=?", provider.DefaultTableName),
 			expectArgs:  []interface{}{"migration_1"},
 			expectErr:   nil,
 		},
 		"db error": {
 			name:        "migration_2",
 			execError:   errors.New("some db error"),
-			expectQuery: fmt.Sprintf("DELETE FROM %s WHERE name=?", provider.DefaultTableName),
+			expectQuery: fmt.Sprintf("DELETE FROM \"%s\" WHERE name=?", provider.DefaultTableName),
 			expectArgs:  []interface{}{"migration_2"},
 			expectErr:   errors.New("some db error"),
 		},
@@ -204,3 +291,165 @@ func TestSqliteProvider_DeleteApplied(t *testing.T) {
 		})
 	}
 }
+
+func TestSqliteProvider_MarkAppliedTx(t *testing.T) {
+	now := time.Now()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(fmt.Sprintf("INSERT INTO \"%s\" (name, time, checksum, dirty, duration_ms, hostname, os_user, app_version, direction, error, batch, sequence) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, (SELECT COALESCE(MAX(sequence), 0) + 1 FROM \"%s\")) ON CONFLICT (name) DO UPDATE SET time = excluded.time, checksum = excluded.checksum, dirty = excluded.dirty, duration_ms = excluded.duration_ms, hostname = excluded.hostname, os_user = excluded.os_user, app_version = excluded.app_version, direction = excluded.direction, error = excluded.error, batch = excluded.batch", provider.DefaultTableName, provider.DefaultTableName)).
+		WithArgs("migration_1", now, "abc", false, int64(0), "", "", "", "", "", int64(0)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+
+	p := sqlite.NewSqliteProvider(db)
+	err = p.MarkAppliedTx(context.Background(), tx, provider.HistoryRecord{Name: "migration_1", AppliedAt: now, Checksum: "abc"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, tx.Commit())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSqliteProvider_DeleteAppliedTx(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer db.Close()
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(fmt.Sprintf("DELETE FROM \"%s\" WHERE name=?", provider.DefaultTableName)).
+		WithArgs("migration_1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+
+	p := sqlite.NewSqliteProvider(db)
+	err = p.DeleteAppliedTx(context.Background(), tx, "migration_1")
+	assert.NoError(t, err)
+
+	assert.NoError(t, tx.Commit())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSqliteProvider_Lock(t *testing.T) {
+	createQuery := fmt.Sprintf("create table if not exists \"%s_lock\" ( id integer not null primary key check (id = 1), time timestamp, owner varchar(255) not null default '' );", provider.DefaultTableName)
+	columnsQuery := fmt.Sprintf("SELECT * FROM \"%s_lock\" WHERE 1 = 0", provider.DefaultTableName)
+	insertQuery := fmt.Sprintf("INSERT OR IGNORE INTO \"%s_lock\" (id, time, owner) VALUES (1, ?, ?)", provider.DefaultTableName)
+	holderQuery := fmt.Sprintf("SELECT owner, time FROM \"%s_lock\" WHERE id=1", provider.DefaultTableName)
+	since := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	expectLockTable := func(mock sqlmock.Sqlmock) {
+		mock.ExpectExec(createQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(columnsQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "time", "owner"}))
+	}
+
+	t.Run("waits until lock is released", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		assert.NoError(t, err)
+
+		expectLockTable(mock)
+		mock.ExpectExec(insertQuery).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(holderQuery).WillReturnRows(sqlmock.NewRows([]string{"owner", "time"}).AddRow("host:42", time.Now()))
+		mock.ExpectExec(insertQuery).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+
+		p := sqlite.NewSqliteProvider(db, provider.WithLockPollInterval(time.Millisecond))
+
+		assert.NoError(t, p.Lock(context.Background()))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("context is done", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		assert.NoError(t, err)
+
+		expectLockTable(mock)
+		mock.ExpectExec(insertQuery).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(holderQuery).WillReturnRows(sqlmock.NewRows([]string{"owner", "time"}).AddRow("host:42", since))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+
+		p := sqlite.NewSqliteProvider(db, provider.WithLockPollInterval(time.Second))
+
+		err = p.Lock(ctx)
+		assert.EqualError(t, err, fmt.Sprintf("lock in \"%s_lock\" is held by 'host:42' since 2021-03-04T05:06:07Z, delete the row if the process is gone: context deadline exceeded", provider.DefaultTableName))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("stale lock is taken over", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		assert.NoError(t, err)
+
+		expectLockTable(mock)
+		mock.ExpectExec(insertQuery).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(holderQuery).WillReturnRows(sqlmock.NewRows([]string{"owner", "time"}).AddRow("host:42", since))
+		mock.ExpectExec(fmt.Sprintf("DELETE FROM \"%s_lock\" WHERE id=1 AND owner=?", provider.DefaultTableName)).
+			WithArgs("host:42").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(insertQuery).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+
+		p := sqlite.NewSqliteProvider(db, provider.WithLockPollInterval(time.Millisecond), provider.WithLockStaleTimeout(time.Hour))
+
+		assert.NoError(t, p.Lock(context.Background()))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("lock table without owner", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		assert.NoError(t, err)
+
+		mock.ExpectExec(createQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(columnsQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "time"}))
+		mock.ExpectExec(fmt.Sprintf("ALTER TABLE \"%s_lock\" ADD COLUMN owner varchar(255) not null default ''", provider.DefaultTableName)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(insertQuery).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+
+		p := sqlite.NewSqliteProvider(db, provider.WithLockPollInterval(time.Millisecond))
+
+		assert.NoError(t, p.Lock(context.Background()))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSqliteProvider_Unlock(t *testing.T) {
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d", hostname, os.Getpid())
+	unlockQuery := fmt.Sprintf("DELETE FROM \"%s_lock\" WHERE id=1 AND owner=?", provider.DefaultTableName)
+
+	t.Run("lock is held", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		assert.NoError(t, err)
+
+		mock.ExpectExec(unlockQuery).WithArgs(owner).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		p := sqlite.NewSqliteProvider(db)
+
+		assert.NoError(t, p.Unlock(context.Background()))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("lock is taken over", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		defer db.Close()
+		assert.NoError(t, err)
+
+		mock.ExpectExec(unlockQuery).WithArgs(owner).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		p := sqlite.NewSqliteProvider(db)
+
+		assert.EqualError(t, p.Unlock(context.Background()),
+			fmt.Sprintf("lock in \"%s_lock\" isn't held by '%s', it was taken over by another process", provider.DefaultTableName, owner))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
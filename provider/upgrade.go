@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// HistoryVersion - version of the migration history table layout.
+// Version 1 is the original layout with name and time columns only, version 2 has columns up to error,
+// version 3 has SequenceColumn and stores time in UTC, version 4 has batch column
+const HistoryVersion = 4
+
+// Querier - interface of *sql.DB, *sql.Tx and *sql.Conn
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Upgrade - upgrade of the migration history table to HistoryVersion.
+// The version is stored in the meta table with id and version columns
+type Upgrade struct {
+	// Table - quoted name of the migration history table
+	Table string
+	// MetaTable - quoted name of the table holding the version of the history table
+	MetaTable string
+	// ColumnDefinitions - dialect specific definitions of HistoryColumns and SequenceColumn that older layouts don't have
+	ColumnDefinitions map[string]string
+	// SequenceStatements - dialect specific statements run after SequenceColumn is added to a table of an older layout,
+	// e.g. changes of column types or numbering of existing rows
+	SequenceStatements []string
+}
+
+// Run - function upgrading the history table in place if its stored version is older than HistoryVersion.
+// A table without stored version is upgraded according to its columns, so any older layout is detected.
+// Both tables should exist, and the caller is responsible for running Run inside a transaction or a lock
+func (u Upgrade) Run(ctx context.Context, q Querier) error {
+	version, err := u.version(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	if version >= HistoryVersion {
+		return nil
+	}
+
+	columns, err := u.columns(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	for _, column := range append(append([]string{}, HistoryColumns...), SequenceColumn) {
+		if columns[column] {
+			continue
+		}
+
+		definition, ok := u.ColumnDefinitions[column]
+		if !ok {
+			return fmt.Errorf("can't upgrade %s: there is no definition of column %s", u.Table, column)
+		}
+
+		_, err = q.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", u.Table, column, definition))
+		if err != nil {
+			return fmt.Errorf("can't upgrade %s: %w", u.Table, err)
+		}
+	}
+
+	// rows of a table with SequenceColumn are numbered already, and numbering them again would lose the order of applying
+	if !columns[SequenceColumn] {
+		for _, statement := range u.SequenceStatements {
+			_, err = q.ExecContext(ctx, statement)
+			if err != nil {
+				return fmt.Errorf("can't upgrade %s: %w", u.Table, err)
+			}
+		}
+	}
+
+	_, err = q.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", u.MetaTable))
+	if err != nil {
+		return err
+	}
+
+	_, err = q.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (id, version) VALUES (1, %d)", u.MetaTable, HistoryVersion))
+	return err
+}
+
+// version returns the stored version of the history table or 0 if there is no stored version
+func (u Upgrade) version(ctx context.Context, q Querier) (int, error) {
+	rows, err := q.QueryContext(ctx, fmt.Sprintf("SELECT version FROM %s WHERE id = 1", u.MetaTable))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	version := 0
+	if rows.Next() {
+		err = rows.Scan(&version)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return version, rows.Err()
+}
+
+// columns returns set of lower-cased column names of the history table
+func (u Upgrade) columns(ctx context.Context, q Querier) (map[string]bool, error) {
+	rows, err := q.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", u.Table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]bool, len(names))
+	for _, name := range names {
+		columns[strings.ToLower(name)] = true
+	}
+
+	return columns, nil
+}
+
+// SequenceBackfill - function returning a statement numbering rows of the history table in the order of time and name.
+// The statement reads the updated table in a subquery, so it works with postgres and sqlite but not with mysql
+func SequenceBackfill(table string) string {
+	return fmt.Sprintf("UPDATE %s AS h SET %s = (SELECT COUNT(*) FROM %s AS older WHERE older.time < h.time OR (older.time = h.time AND older.name <= h.name))",
+		table, SequenceColumn, table)
+}
@@ -0,0 +1,134 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/iamsalnikov/mymigrate/provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpgrade_Run(t *testing.T) {
+	definitions := map[string]string{
+		"checksum":    "TEXT",
+		"dirty":       "BOOL",
+		"duration_ms": "INT",
+		"hostname":    "TEXT",
+		"os_user":     "TEXT",
+		"app_version": "TEXT",
+		"direction":   "TEXT",
+		"error":       "TEXT",
+		"batch":       "BIGINT",
+		"sequence":    "BIGINT",
+	}
+
+	layoutV2 := []string{"name", "time", "checksum", "dirty", "duration_ms", "hostname", "os_user", "app_version", "direction", "error"}
+
+	cases := map[string]struct {
+		version     *sqlmock.Rows
+		columns     []string
+		definitions map[string]string
+
+		expectAlter      []string
+		expectStatements bool
+		expectErr        error
+	}{
+		"table is up to date": {
+			version:     sqlmock.NewRows([]string{"version"}).AddRow(provider.HistoryVersion),
+			definitions: definitions,
+		},
+		"new table without version": {
+			version:     sqlmock.NewRows([]string{"version"}),
+			columns:     append(append([]string{}, provider.HistoryColumns...), provider.SequenceColumn),
+			definitions: definitions,
+			expectAlter: []string{},
+		},
+		"layout without sequence": {
+			version:          sqlmock.NewRows([]string{"version"}).AddRow(2),
+			columns:          layoutV2,
+			definitions:      definitions,
+			expectAlter:      []string{"batch BIGINT", "sequence BIGINT"},
+			expectStatements: true,
+		},
+		"layout without batch": {
+			version:     sqlmock.NewRows([]string{"version"}).AddRow(3),
+			columns:     append(append([]string{}, layoutV2...), provider.SequenceColumn),
+			definitions: definitions,
+			expectAlter: []string{"batch BIGINT"},
+		},
+		"two-column layout": {
+			version:     sqlmock.NewRows([]string{"version"}),
+			columns:     []string{"NAME", "TIME"},
+			definitions: definitions,
+			expectAlter: []string{
+				"checksum TEXT", "dirty BOOL", "duration_ms INT", "hostname TEXT",
+				"os_user TEXT", "app_version TEXT", "direction TEXT", "error TEXT", "batch BIGINT", "sequence BIGINT",
+			},
+			expectStatements: true,
+		},
+		"layout with checksum and dirty": {
+			version:     sqlmock.NewRows([]string{"version"}).AddRow(1),
+			columns:     []string{"name", "time", "checksum", "dirty"},
+			definitions: definitions,
+			expectAlter: []string{
+				"duration_ms INT", "hostname TEXT", "os_user TEXT", "app_version TEXT", "direction TEXT", "error TEXT",
+				"batch BIGINT", "sequence BIGINT",
+			},
+			expectStatements: true,
+		},
+		"unknown column definition": {
+			version:     sqlmock.NewRows([]string{"version"}),
+			columns:     []string{"name", "time"},
+			definitions: map[string]string{},
+			expectErr:   errors.New("can't upgrade history: there is no definition of column checksum"),
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			defer db.Close()
+			assert.NoError(t, err)
+
+			mock.ExpectQuery("SELECT version FROM history_meta WHERE id = 1").WillReturnRows(c.version)
+			if c.columns != nil {
+				mock.ExpectQuery("SELECT * FROM history WHERE 1 = 0").WillReturnRows(sqlmock.NewRows(c.columns))
+			}
+
+			for _, alter := range c.expectAlter {
+				mock.ExpectExec(fmt.Sprintf("ALTER TABLE history ADD COLUMN %s", alter)).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+
+			if c.expectStatements {
+				mock.ExpectExec("UPDATE history SET sequence = 1").WillReturnResult(sqlmock.NewResult(0, 0))
+			}
+
+			if c.expectAlter != nil {
+				mock.ExpectExec("DELETE FROM history_meta").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(fmt.Sprintf("INSERT INTO history_meta (id, version) VALUES (1, %d)", provider.HistoryVersion)).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			}
+
+			u := provider.Upgrade{
+				Table:              "history",
+				MetaTable:          "history_meta",
+				ColumnDefinitions:  c.definitions,
+				SequenceStatements: []string{"UPDATE history SET sequence = 1"},
+			}
+			err = u.Run(context.Background(), db)
+
+			assert.Equal(t, c.expectErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSequenceBackfill(t *testing.T) {
+	assert.Equal(t,
+		"UPDATE history AS h SET sequence = (SELECT COUNT(*) FROM history AS older WHERE older.time < h.time OR (older.time = h.time AND older.name <= h.name))",
+		provider.SequenceBackfill("history"))
+}
@@ -0,0 +1,53 @@
+package mymigrate
+
+import (
+	"context"
+)
+
+// Redo reverts particular number of the latest applied migrations and applies the same migrations again.
+// Pass 0 as a number to redo all migrations
+func (m *Migrator) Redo(number int) ([]string, []string, error) {
+	return m.RedoContext(context.Background(), number)
+}
+
+// RedoContext reverts particular number of the latest applied migrations and applies the same migrations again.
+// It returns reverted migrations in the order of reverting and applied migrations in the order of applying.
+// Migrations are applied only if all of them were reverted, and the migration lock is held for the whole redo
+func (m *Migrator) RedoContext(ctx context.Context, number int) ([]string, []string, error) {
+	err := m.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var reverted, applied []string
+	err = m.withLock(ctx, func() error {
+		// the history read before reverting is enough to number the batch of applied migrations
+		var records []HistoryRecord
+		var err error
+		reverted, err = m.runLocked(ctx, DirectionDown, func() ([]string, []HistoryRecord, error) {
+			var names []string
+			names, records, err = m.namesToDown(ctx, number)
+			return names, records, err
+		})
+		if err != nil {
+			return err
+		}
+
+		applied, err = m.runLocked(ctx, DirectionUp, func() ([]string, []HistoryRecord, error) {
+			return reversed(reverted), records, nil
+		})
+		return err
+	})
+
+	return reverted, applied, err
+}
+
+// reversed returns names in the reverse order
+func reversed(names []string) []string {
+	result := make([]string, 0, len(names))
+	for i := len(names) - 1; i >= 0; i-- {
+		result = append(result, names[i])
+	}
+
+	return result
+}
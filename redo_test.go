@@ -0,0 +1,121 @@
+package mymigrate
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iamsalnikov/mymigrate/migrationtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrator_Redo(t *testing.T) {
+	downErr := errors.New("down error")
+	upErr := errors.New("up error")
+
+	testCases := map[string]struct {
+		number  int
+		downErr error
+		upErr   error
+
+		expectCalls    []string
+		expectReverted []string
+		expectApplied  []string
+		expectErr      error
+	}{
+		"redo the latest migration": {
+			number:         1,
+			expectCalls:    []string{"down mig_003", "up mig_003"},
+			expectReverted: []string{"mig_003"},
+			expectApplied:  []string{"mig_003"},
+		},
+		"redo two migrations": {
+			number:         2,
+			expectCalls:    []string{"down mig_003", "down mig_002", "up mig_002", "up mig_003"},
+			expectReverted: []string{"mig_003", "mig_002"},
+			expectApplied:  []string{"mig_002", "mig_003"},
+		},
+		"redo all migrations": {
+			number:         0,
+			expectCalls:    []string{"down mig_003", "down mig_002", "down mig_001", "up mig_001", "up mig_002", "up mig_003"},
+			expectReverted: []string{"mig_003", "mig_002", "mig_001"},
+			expectApplied:  []string{"mig_001", "mig_002", "mig_003"},
+		},
+		"down fails": {
+			number:         2,
+			downErr:        downErr,
+			expectCalls:    []string{"down mig_003"},
+			expectReverted: []string{},
+			expectApplied:  nil,
+			expectErr:      downErr,
+		},
+		"up fails": {
+			number:         2,
+			upErr:          upErr,
+			expectCalls:    []string{"down mig_003", "down mig_002", "up mig_002"},
+			expectReverted: []string{"mig_003", "mig_002"},
+			expectApplied:  []string{},
+			expectErr:      upErr,
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			provider := migrationtest.NewMockDbProvider(ctrl)
+			provider.EXPECT().GetDb().AnyTimes()
+			provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+			provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords("mig_003", "mig_002", "mig_001"), nil)
+			provider.EXPECT().MarkApplied(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+			provider.EXPECT().DeleteApplied(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			calls := make([]string, 0)
+			m := NewMigrator(provider)
+			for _, name := range []string{"mig_001", "mig_002", "mig_003"} {
+				name := name
+				m.Add(
+					name,
+					func(db *sql.DB) error {
+						calls = append(calls, "up "+name)
+						return tc.upErr
+					},
+					func(db *sql.DB) error {
+						calls = append(calls, "down "+name)
+						return tc.downErr
+					},
+				)
+			}
+
+			reverted, applied, err := m.Redo(tc.number)
+			assert.Equal(t, tc.expectErr, err)
+			assert.Equal(t, tc.expectCalls, calls)
+			assert.Equal(t, tc.expectReverted, reverted)
+			assert.Equal(t, tc.expectApplied, applied)
+		})
+	}
+}
+
+func TestMigrator_DownRejectsNegativeNumber(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	provider := migrationtest.NewMockDbProvider(ctrl)
+	provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords("mig_001"), nil).AnyTimes()
+
+	m := NewMigrator(provider)
+	m.Add("mig_001", func(db *sql.DB) error { return nil }, func(db *sql.DB) error {
+		t.Error("migration shouldn't be reverted")
+		return nil
+	})
+
+	reverted, applied, err := m.Redo(-1)
+	assert.EqualError(t, err, "number of migrations should not be negative, got -1")
+	assert.Empty(t, reverted)
+	assert.Empty(t, applied)
+
+	_, err = m.Down(-1)
+	assert.EqualError(t, err, "number of migrations should not be negative, got -1")
+
+	_, err = m.DownPlan(-1)
+	assert.EqualError(t, err, "number of migrations should not be negative, got -1")
+}
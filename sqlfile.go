@@ -0,0 +1,471 @@
+package mymigrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	// marker of the UP section in a single-file SQL migration
+	sqlUpMarker = "-- +up"
+	// marker of the DOWN section in a single-file SQL migration
+	sqlDownMarker = "-- +down"
+	// directive that opts SQL migration out of a transaction
+	sqlNoTxDirective = "-- +notransaction"
+	// directive that marks SQL migration as irreversible
+	sqlIrreversibleDirective = "-- +irreversible"
+	// directive that lists migrations the SQL migration depends on
+	sqlDependsDirective = "-- +depends"
+	// directive that starts a statement which isn't split by semicolons, e.g. a trigger
+	sqlStatementBeginDirective = "-- +statementbegin"
+	// directive that ends a statement started by sqlStatementBeginDirective
+	sqlStatementEndDirective = "-- +statementend"
+)
+
+// sqlMigration is a migration parsed from SQL files
+type sqlMigration struct {
+	name string
+	// SQL of the migration sections without directives. It is split into statements when the migration runs,
+	// because splitting depends on the database
+	up   string
+	down string
+	noTx bool
+	// migration can't be reverted
+	irreversible bool
+	// names of migrations the migration depends on
+	dependsOn []string
+}
+
+// checksum returns sha256 of the migration statements.
+// Statements are split with backslash escapes like earlier releases did, so recorded checksums don't change
+func (sm sqlMigration) checksum() string {
+	h := sha256.New()
+	for _, statement := range splitStatements(sm.up, true) {
+		_, _ = io.WriteString(h, statement+"\n")
+	}
+
+	_, _ = io.WriteString(h, sqlDownMarker+"\n")
+	for _, statement := range splitStatements(sm.down, true) {
+		_, _ = io.WriteString(h, statement+"\n")
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AddDir adds SQL migrations from the directory to the migrator's queue.
+// See AddFS for the files layout
+func (m *Migrator) AddDir(dir string) error {
+	return m.AddFS(os.DirFS(dir), ".")
+}
+
+// AddFS adds SQL migrations from the dir of fsys to the migrator's queue, so embed.FS can be used.
+// A migration is either a pair of NAME.up.sql and NAME.down.sql files
+// or a single NAME.sql file with "-- +up" and "-- +down" sections.
+// SQL migrations run inside a transaction unless they contain a "-- +notransaction" line.
+// A migration with a "-- +irreversible" line can't be reverted.
+// A "-- +depends NAME..." line declares migrations that have to be applied before the migration.
+// Lines between "-- +statementbegin" and "-- +statementend" are a single statement even if they contain semicolons
+func (m *Migrator) AddFS(fsys fs.FS, dir string) error {
+	parsed, err := readSQLMigrations(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, sm := range parsed {
+		m.addSQL(sm)
+	}
+
+	return nil
+}
+
+func (m *Migrator) addSQL(sm sqlMigration) {
+	// a migration without down statements can't be reverted, like a Go migration without a down function
+	mg := mig{
+		name:         sm.name,
+		upSQL:        sm.up,
+		downSQL:      sm.down,
+		checksum:     sm.checksum(),
+		dependsOn:    sm.dependsOn,
+		irreversible: sm.irreversible || len(splitStatements(sm.down, false)) == 0,
+	}
+
+	if sm.noTx {
+		mg.up = func(ctx context.Context, db *sql.DB) error {
+			return m.execStatements(ctx, db, sm.name, m.sqlStatements(sm.up))
+		}
+		mg.down = func(ctx context.Context, db *sql.DB) error {
+			return m.execStatements(ctx, db, sm.name, m.sqlStatements(sm.down))
+		}
+	} else {
+		mg.upTx = func(ctx context.Context, tx *sql.Tx) error {
+			return m.execStatements(ctx, tx, sm.name, m.sqlStatements(sm.up))
+		}
+		mg.downTx = func(ctx context.Context, tx *sql.Tx) error {
+			return m.execStatements(ctx, tx, sm.name, m.sqlStatements(sm.down))
+		}
+	}
+
+	m.add(mg, nil)
+}
+
+// sqlStatements splits SQL of a migration into statements according to the database of the provider
+func (m *Migrator) sqlStatements(content string) []string {
+	escaper, ok := m.provider.(BackslashEscaper)
+
+	return splitStatements(content, ok && escaper.BackslashEscapes())
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// execStatements executes statements of the named migration one by one logging them at debug level
+func (m *Migrator) execStatements(ctx context.Context, db execer, name string, statements []string) error {
+	for _, statement := range statements {
+		m.logger.Debug("executing statement", "migration", name, "statement", statement)
+		_, err := db.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readSQLMigrations reads SQL migrations from the dir of fsys sorted by name
+func readSQLMigrations(fsys fs.FS, dir string) ([]sqlMigration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ups := make(map[string]string)
+	downs := make(map[string]string)
+	singles := make(map[string]string)
+	for _, entry := range entries {
+		filename := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(filename, ".sql") {
+			continue
+		}
+
+		switch {
+		case strings.HasSuffix(filename, ".up.sql"):
+			ups[strings.TrimSuffix(filename, ".up.sql")] = filename
+		case strings.HasSuffix(filename, ".down.sql"):
+			downs[strings.TrimSuffix(filename, ".down.sql")] = filename
+		default:
+			singles[strings.TrimSuffix(filename, ".sql")] = filename
+		}
+	}
+
+	result := make([]sqlMigration, 0, len(ups)+len(singles))
+	for name, filename := range singles {
+		if _, ok := ups[name]; ok {
+			return nil, fmt.Errorf("migration '%s' is defined by both %s and %s", name, filename, ups[name])
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, filename))
+		if err != nil {
+			return nil, err
+		}
+
+		sm, err := parseSQLMigration(name, string(content))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+
+		result = append(result, sm)
+	}
+
+	for name, filename := range downs {
+		if _, ok := ups[name]; !ok {
+			return nil, fmt.Errorf("%s has no matching %s.up.sql", filename, name)
+		}
+	}
+
+	for name, filename := range ups {
+		upContent, err := fs.ReadFile(fsys, path.Join(dir, filename))
+		if err != nil {
+			return nil, err
+		}
+
+		downContent := []byte{}
+		if downFilename, ok := downs[name]; ok {
+			downContent, err = fs.ReadFile(fsys, path.Join(dir, downFilename))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		dependsOn, up := parseDepends(string(upContent))
+		down := string(downContent)
+		result = append(result, sqlMigration{
+			name:         name,
+			up:           removeDirectives(up),
+			down:         removeDirectives(down),
+			noTx:         hasNoTxDirective(up) || hasNoTxDirective(down),
+			irreversible: hasLine(up, sqlIrreversibleDirective) || hasLine(down, sqlIrreversibleDirective),
+			dependsOn:    dependsOn,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].name < result[j].name
+	})
+
+	return result, nil
+}
+
+// parseSQLMigration parses a single-file SQL migration with "-- +up" and "-- +down" sections
+func parseSQLMigration(name, content string) (sqlMigration, error) {
+	dependsOn, content := parseDepends(content)
+
+	var up, down strings.Builder
+	var section *strings.Builder
+
+	for _, line := range strings.Split(content, "\n") {
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case sqlUpMarker:
+			section = &up
+			continue
+		case sqlDownMarker:
+			section = &down
+			continue
+		case sqlNoTxDirective, sqlIrreversibleDirective:
+			continue
+		}
+
+		if section != nil {
+			section.WriteString(line)
+			section.WriteString("\n")
+		}
+	}
+
+	if !hasLine(content, sqlUpMarker) {
+		return sqlMigration{}, fmt.Errorf("there is no '%s' section", sqlUpMarker)
+	}
+
+	return sqlMigration{
+		name:         name,
+		up:           up.String(),
+		down:         down.String(),
+		noTx:         hasNoTxDirective(content),
+		irreversible: hasLine(content, sqlIrreversibleDirective),
+		dependsOn:    dependsOn,
+	}, nil
+}
+
+// parseDepends returns names listed in "-- +depends" lines and content without these lines
+func parseDepends(content string) ([]string, string) {
+	var dependsOn []string
+
+	lines := strings.Split(content, "\n")
+	result := make([]string, 0, len(lines))
+	for _, l := range lines {
+		fields := strings.Fields(l)
+		if len(fields) >= 2 && fields[0] == "--" && strings.EqualFold(fields[1], strings.TrimPrefix(sqlDependsDirective, "-- ")) {
+			dependsOn = append(dependsOn, fields[2:]...)
+			continue
+		}
+
+		result = append(result, l)
+	}
+
+	return dependsOn, strings.Join(result, "\n")
+}
+
+func hasNoTxDirective(content string) bool {
+	return hasLine(content, sqlNoTxDirective)
+}
+
+// hasLine tells whether content has the line ignoring case and surrounding spaces
+func hasLine(content, line string) bool {
+	for _, l := range strings.Split(content, "\n") {
+		if strings.EqualFold(strings.TrimSpace(l), line) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeDirectives removes lines of directives from content of a SQL file
+func removeDirectives(content string) string {
+	return removeLine(removeLine(content, sqlNoTxDirective), sqlIrreversibleDirective)
+}
+
+// removeLine removes the line from content ignoring case and surrounding spaces
+func removeLine(content, line string) string {
+	lines := strings.Split(content, "\n")
+	result := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if !strings.EqualFold(strings.TrimSpace(l), line) {
+			result = append(result, l)
+		}
+	}
+
+	return strings.Join(result, "\n")
+}
+
+// splitStatements splits SQL into statements by semicolons.
+// Semicolons inside quotes, comments, dollar-quoted strings and statement blocks don't split statements.
+// A backslash escapes a quote inside postgres E'...' strings and inside any strings if backslashEscapes is set (MySQL).
+// Statements that contain only comments are skipped
+func splitStatements(content string, backslashEscapes bool) []string {
+	statements := make([]string, 0)
+
+	var current strings.Builder
+	hasCode := false
+	flush := func() {
+		statement := strings.TrimSpace(current.String())
+		if hasCode && len(statement) > 0 {
+			statements = append(statements, statement)
+		}
+
+		current.Reset()
+		hasCode = false
+	}
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '-' && strings.HasPrefix(content[i:], "--"):
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				end = len(content) - i
+			}
+
+			if strings.EqualFold(strings.TrimSpace(content[i:i+end]), sqlStatementBeginDirective) {
+				flush()
+				block, blockEnd := statementBlock(content[i+end:])
+				current.WriteString(block)
+				hasCode = true
+				flush()
+				i += end + blockEnd - 1
+				continue
+			}
+
+			current.WriteString(content[i : i+end])
+			i += end - 1
+		case c == '/' && strings.HasPrefix(content[i:], "/*"):
+			end := strings.Index(content[i+2:], "*/")
+			if end < 0 {
+				end = len(content) - i
+			} else {
+				end += 4
+			}
+
+			current.WriteString(content[i : i+end])
+			i += end - 1
+		case c == '\'' || c == '"' || c == '`':
+			escapes := c != '`' && (backslashEscapes || c == '\'' && isEscapeStringPrefix(content, i))
+			end := closingQuote(content, i+1, c, escapes)
+			current.WriteString(content[i:end])
+			hasCode = true
+			i = end - 1
+		case c == '$' && dollarTag(content[i:]) != "":
+			tag := dollarTag(content[i:])
+			end := strings.Index(content[i+len(tag):], tag)
+			if end < 0 {
+				end = len(content) - i
+			} else {
+				end += 2 * len(tag)
+			}
+
+			current.WriteString(content[i : i+end])
+			hasCode = true
+			i += end - 1
+		case c == ';':
+			current.WriteByte(c)
+			flush()
+		default:
+			current.WriteByte(c)
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				hasCode = true
+			}
+		}
+	}
+
+	flush()
+
+	return statements
+}
+
+// statementBlock returns the statement at the beginning of content up to the "-- +statementend" line
+// and index right after that line. The statement lasts till the end of content if there is no such line
+func statementBlock(content string) (string, int) {
+	for offset := 0; offset < len(content); {
+		end := strings.IndexByte(content[offset:], '\n')
+		if end < 0 {
+			end = len(content) - offset
+		}
+
+		if strings.EqualFold(strings.TrimSpace(content[offset:offset+end]), sqlStatementEndDirective) {
+			return content[:offset], offset + end
+		}
+
+		offset += end + 1
+	}
+
+	return content, len(content)
+}
+
+// closingQuote returns index right after the quote that closes a string started before from.
+// Doubled quotes and, if escapes is set, backslash escapes don't close the string
+func closingQuote(content string, from int, quote byte, escapes bool) int {
+	for i := from; i < len(content); i++ {
+		switch {
+		case escapes && content[i] == '\\':
+			i++
+		case content[i] == quote:
+			if i+1 < len(content) && content[i+1] == quote {
+				i++
+				continue
+			}
+
+			return i + 1
+		}
+	}
+
+	return len(content)
+}
+
+// isEscapeStringPrefix tells whether the quote at index i starts a postgres escape string like E'...'
+func isEscapeStringPrefix(content string, i int) bool {
+	if i == 0 || content[i-1] != 'E' && content[i-1] != 'e' {
+		return false
+	}
+
+	return i == 1 || !isIdentifierByte(content[i-2])
+}
+
+// isIdentifierByte tells whether c can be a part of an unquoted identifier
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// dollarTag returns postgres dollar-quote tag like $$ or $body$ at the beginning of s
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '$' {
+			return s[:i+1]
+		}
+
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9') {
+			return ""
+		}
+	}
+
+	return ""
+}
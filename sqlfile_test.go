@@ -0,0 +1,215 @@
+package mymigrate
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang/mock/gomock"
+	"github.com/iamsalnikov/mymigrate/migrationtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitStatements(t *testing.T) {
+	testCases := map[string]struct {
+		content          string
+		backslashEscapes bool
+		expected         []string
+	}{
+		"empty content": {
+			content:  "",
+			expected: []string{},
+		},
+		"only comments": {
+			content:  "-- TODO: write UP logic\n/* nothing; here */\n",
+			expected: []string{},
+		},
+		"several statements": {
+			content:  "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			expected: []string{"CREATE TABLE a (id INT);", "CREATE TABLE b (id INT);"},
+		},
+		"statement without semicolon": {
+			content:  "DROP TABLE a",
+			expected: []string{"DROP TABLE a"},
+		},
+		"semicolons in strings and comments": {
+			content: "INSERT INTO a VALUES ('x;y', \"z;\", `w;`); -- comment; here\nDELETE FROM a WHERE b = 'it''s;';",
+			expected: []string{
+				"INSERT INTO a VALUES ('x;y', \"z;\", `w;`);",
+				"-- comment; here\nDELETE FROM a WHERE b = 'it''s;';",
+			},
+		},
+		"dollar quoted function body": {
+			content: "CREATE FUNCTION f() RETURNS int AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql;\nSELECT $1;",
+			expected: []string{
+				"CREATE FUNCTION f() RETURNS int AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql;",
+				"SELECT $1;",
+			},
+		},
+		"statement block": {
+			content: "CREATE TABLE a (x INT);\n-- +statementbegin\nCREATE TRIGGER t AFTER INSERT ON a BEGIN UPDATE b SET x = 1; END;\n-- +StatementEnd\nSELECT 1;",
+			expected: []string{
+				"CREATE TABLE a (x INT);",
+				"CREATE TRIGGER t AFTER INSERT ON a BEGIN UPDATE b SET x = 1; END;",
+				"SELECT 1;",
+			},
+		},
+		"backslash in standard string": {
+			content:  "INSERT INTO a VALUES ('C:\\'); SELECT 2;",
+			expected: []string{"INSERT INTO a VALUES ('C:\\');", "SELECT 2;"},
+		},
+		"backslash in escape string": {
+			content:  "INSERT INTO a VALUES (E'it\\'s; ok', e'\\'');\nSELECT 2;",
+			expected: []string{"INSERT INTO a VALUES (E'it\\'s; ok', e'\\'');", "SELECT 2;"},
+		},
+		"backslash escapes": {
+			content:          "INSERT INTO a VALUES ('it\\'s; ok', \"\\\"; ok\");\nSELECT 2;",
+			backslashEscapes: true,
+			expected:         []string{"INSERT INTO a VALUES ('it\\'s; ok', \"\\\"; ok\");", "SELECT 2;"},
+		},
+		"statement block without end": {
+			content:  "-- +statementbegin\nBEGIN; END;\n",
+			expected: []string{"BEGIN; END;"},
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			assert.EqualValues(t, tc.expected, splitStatements(tc.content, tc.backslashEscapes))
+		})
+	}
+}
+
+func TestMigrator_AddFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/001_users.up.sql":     {Data: []byte("CREATE TABLE users (id INT);")},
+		"sql/001_users.down.sql":   {Data: []byte("DROP TABLE users;")},
+		"sql/002_index.sql":        {Data: []byte("-- +notransaction\n-- +depends 001_users\n-- +up\nCREATE INDEX CONCURRENTLY i ON users (id);\n-- +down\nDROP INDEX i;\n")},
+		"sql/003_no_down.up.sql":   {Data: []byte("-- +notransaction\n-- +depends 001_users 002_index\nUPDATE users SET id = id;")},
+		"sql/README.md":            {Data: []byte("not a migration")},
+		"sql/nested/004_skip.sql":  {Data: []byte("-- +up\nSELECT 1;")},
+		"other/001_other.down.sql": {Data: []byte("SELECT 1;")},
+	}
+
+	m := NewMigrator(nil)
+	err := m.AddFS(fsys, "sql")
+	assert.NoError(t, err)
+
+	assert.Len(t, m.migrations, 3)
+
+	users := m.migrations["001_users"]
+	assert.True(t, users.transactional())
+	assert.EqualValues(t, []string{"CREATE TABLE users (id INT);"}, m.sqlStatements(users.upSQL))
+	assert.EqualValues(t, []string{"DROP TABLE users;"}, m.sqlStatements(users.downSQL))
+	assert.Empty(t, users.dependsOn)
+
+	index := m.migrations["002_index"]
+	assert.False(t, index.transactional())
+	assert.EqualValues(t, []string{"CREATE INDEX CONCURRENTLY i ON users (id);"}, m.sqlStatements(index.upSQL))
+	assert.EqualValues(t, []string{"DROP INDEX i;"}, m.sqlStatements(index.downSQL))
+	assert.EqualValues(t, []string{"001_users"}, index.dependsOn)
+
+	noDown := m.migrations["003_no_down"]
+	assert.False(t, noDown.transactional())
+	assert.EqualValues(t, []string{"UPDATE users SET id = id;"}, m.sqlStatements(noDown.upSQL))
+	assert.EqualValues(t, []string{}, m.sqlStatements(noDown.downSQL))
+	assert.EqualValues(t, []string{"001_users", "002_index"}, noDown.dependsOn)
+}
+
+func TestMigrator_AddFSErrors(t *testing.T) {
+	testCases := map[string]fstest.MapFS{
+		"down without up": {
+			"001_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		},
+		"single file and pair with the same name": {
+			"001_users.sql":    {Data: []byte("-- +up\nSELECT 1;")},
+			"001_users.up.sql": {Data: []byte("SELECT 1;")},
+		},
+		"single file without up section": {
+			"001_users.sql": {Data: []byte("CREATE TABLE users (id INT);")},
+		},
+	}
+
+	for tcName, fsys := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			m := NewMigrator(nil)
+			assert.Error(t, m.AddFS(fsys, "."))
+			assert.Len(t, m.migrations, 0)
+		})
+	}
+}
+
+func TestMigrator_ApplySQLMigrations(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	ctrl := gomock.NewController(t)
+	provider := migrationtest.NewMockTxProvider(ctrl)
+	provider.EXPECT().GetDb().Return(db).AnyTimes()
+	provider.EXPECT().CreateMigrationsTable(gomock.Any()).Return(nil).AnyTimes()
+	provider.EXPECT().GetApplied(gomock.Any()).Return(historyRecords(), nil)
+	provider.EXPECT().MarkAppliedTx(gomock.Any(), gomock.Any(), appliedRecord("001_users")).Return(nil)
+	provider.EXPECT().MarkApplied(gomock.Any(), dirtyRecord("001_users_seed")).Return(nil)
+	provider.EXPECT().MarkApplied(gomock.Any(), appliedRecord("001_users_seed")).Return(nil)
+	provider.EXPECT().MarkApplied(gomock.Any(), dirtyRecord("002_index")).Return(nil)
+	provider.EXPECT().MarkApplied(gomock.Any(), appliedRecord("002_index")).Return(nil)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE users (id INT);").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO users VALUES (1);").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO users VALUES (2);").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("CREATE INDEX CONCURRENTLY i ON users (id);").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	m := NewMigrator(provider)
+	m.Add(
+		"001_users_seed",
+		func(db *sql.DB) error {
+			_, err := db.Exec("INSERT INTO users VALUES (2);")
+			return err
+		},
+		nil,
+	)
+
+	err = m.AddFS(fstest.MapFS{
+		"001_users.up.sql": {Data: []byte("CREATE TABLE users (id INT);\nINSERT INTO users VALUES (1);\n")},
+		"002_index.sql":    {Data: []byte("-- +up\n-- +notransaction\nCREATE INDEX CONCURRENTLY i ON users (id);\n")},
+	}, ".")
+	assert.NoError(t, err)
+
+	applied, err := m.ApplyContext(context.Background())
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"001_users", "001_users_seed", "002_index"}, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLMigration_Checksum(t *testing.T) {
+	original := sqlMigration{name: "001_users", up: "CREATE TABLE users (id INT);", down: "DROP TABLE users;"}
+	changed := sqlMigration{name: "001_users", up: "CREATE TABLE users (id BIGINT);", down: "DROP TABLE users;"}
+
+	assert.Len(t, original.checksum(), 64)
+	assert.Equal(t, original.checksum(), original.checksum())
+	assert.NotEqual(t, original.checksum(), changed.checksum())
+}
+
+// escapingProvider is a provider of a database that treats a backslash inside strings as an escape character
+type escapingProvider struct {
+	*migrationtest.MockDbProvider
+}
+
+func (p escapingProvider) BackslashEscapes() bool {
+	return true
+}
+
+func TestMigrator_SQLStatementsOfProvider(t *testing.T) {
+	content := "INSERT INTO a VALUES ('it\\'s; ok');"
+
+	m := NewMigrator(migrationtest.NewMockDbProvider(gomock.NewController(t)))
+	assert.EqualValues(t, []string{"INSERT INTO a VALUES ('it\\'s;", "ok');"}, m.sqlStatements(content))
+
+	m.SetDatabaseProvider(escapingProvider{MockDbProvider: migrationtest.NewMockDbProvider(gomock.NewController(t))})
+	assert.EqualValues(t, []string{content}, m.sqlStatements(content))
+}
@@ -0,0 +1,96 @@
+package mymigrate
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// MigrationState is a state of a migration in the database
+type MigrationState string
+
+const (
+	// StateApplied means that the registered migration is applied
+	StateApplied MigrationState = "applied"
+	// StatePending means that the registered migration isn't applied yet
+	StatePending MigrationState = "pending"
+	// StateNotRegistered means that the migration is applied but isn't registered anymore
+	StateNotRegistered MigrationState = "not registered"
+	// StateOutOfOrder means that the registered migration isn't applied yet
+	// but it is older than the latest applied one
+	StateOutOfOrder MigrationState = "out of order"
+	// StateDirty means that the migration was started but hasn't finished
+	StateDirty MigrationState = "dirty"
+)
+
+// MigrationStatus describes a state of a single migration
+type MigrationStatus struct {
+	Name  string
+	State MigrationState
+	// AppliedAt is the time when the migration was applied. It is zero for migrations that aren't applied
+	AppliedAt time.Time
+	// Irreversible tells whether the registered migration can't be reverted
+	Irreversible bool
+}
+
+// Status returns states of registered and applied migrations
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	return m.StatusContext(context.Background())
+}
+
+// StatusContext returns states of registered and applied migrations sorted by name
+func (m *Migrator) StatusContext(ctx context.Context) ([]MigrationStatus, error) {
+	records, err := m.getApplied(ctx, m.provider)
+	if err != nil {
+		return nil, err
+	}
+
+	m.observePending(records)
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations)+len(records))
+	applied := make(map[string]bool, len(records))
+	latestApplied := latestAppliedName(records)
+	for _, record := range records {
+		applied[record.Name] = true
+
+		state := StateApplied
+		mig, ok := m.migrations[record.Name]
+		if !ok {
+			state = StateNotRegistered
+		}
+
+		if record.Dirty {
+			state = StateDirty
+		}
+
+		statuses = append(statuses, MigrationStatus{
+			Name:         record.Name,
+			State:        state,
+			AppliedAt:    record.AppliedAt,
+			Irreversible: ok && !mig.reversible(),
+		})
+	}
+
+	for name, mig := range m.migrations {
+		if applied[name] {
+			continue
+		}
+
+		state := StatePending
+		if name < latestApplied {
+			state = StateOutOfOrder
+		}
+
+		statuses = append(statuses, MigrationStatus{
+			Name:         name,
+			State:        state,
+			Irreversible: !mig.reversible(),
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+
+	return statuses, nil
+}
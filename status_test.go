@@ -0,0 +1,52 @@
+package mymigrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrator_Status(t *testing.T) {
+	appliedAt := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	noop := func(db *sql.DB) error { return nil }
+
+	m := NewMigrator(nil)
+	for _, name := range []string{"mig_001", "mig_002", "mig_004", "mig_005"} {
+		m.Add(name, noop, noop)
+	}
+
+	m.getApplied = func(ctx context.Context, provider DbProvider) ([]HistoryRecord, error) {
+		return []HistoryRecord{
+			{Name: "mig_004", AppliedAt: appliedAt},
+			{Name: "mig_003", AppliedAt: appliedAt},
+			{Name: "mig_001", AppliedAt: appliedAt},
+		}, nil
+	}
+
+	statuses, err := m.Status()
+	assert.NoError(t, err)
+	assert.EqualValues(t, []MigrationStatus{
+		{Name: "mig_001", State: StateApplied, AppliedAt: appliedAt},
+		{Name: "mig_002", State: StateOutOfOrder},
+		{Name: "mig_003", State: StateNotRegistered, AppliedAt: appliedAt},
+		{Name: "mig_004", State: StateApplied, AppliedAt: appliedAt},
+		{Name: "mig_005", State: StatePending},
+	}, statuses)
+}
+
+func TestMigrator_StatusError(t *testing.T) {
+	testErr := errors.New("test error")
+
+	m := NewMigrator(nil)
+	m.getApplied = func(ctx context.Context, provider DbProvider) ([]HistoryRecord, error) {
+		return nil, testErr
+	}
+
+	statuses, err := m.Status()
+	assert.Equal(t, testErr, err)
+	assert.Nil(t, statuses)
+}
@@ -0,0 +1,44 @@
+package mymigrate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/iamsalnikov/mymigrate/provider"
+)
+
+// ErrInvalidMigrations is returned by Validate and by runs if registered migrations are invalid
+var ErrInvalidMigrations = errors.New("invalid migrations")
+
+// Validate returns an error describing every problem of registered migrations:
+// duplicate, empty or too long names, missing up functions, unregistered and cyclic dependencies.
+// Apply, Down and their plans run it before touching the database
+func (m *Migrator) Validate() error {
+	problems := append([]string{}, m.problems...)
+	problems = append(problems, m.dependencyProblems()...)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrInvalidMigrations, strings.Join(problems, "; "))
+}
+
+// registrationProblem returns a problem of the migration that is being added or empty string if it is valid
+func (m *Migrator) registrationProblem(mig mig) string {
+	switch {
+	case len(mig.name) == 0:
+		return "migration name is empty"
+	case utf8.RuneCountInString(mig.name) > provider.MaxNameLength:
+		return fmt.Sprintf("migration name '%.32s...' is longer than %d characters", mig.name, provider.MaxNameLength)
+	case mig.up == nil && mig.upTx == nil:
+		return fmt.Sprintf("migration '%s' has no up function", mig.name)
+	}
+
+	if _, ok := m.migrations[mig.name]; ok {
+		return fmt.Sprintf("migration '%s' is registered more than once", mig.name)
+	}
+
+	return ""
+}
@@ -0,0 +1,152 @@
+package mymigrate
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/golang/mock/gomock"
+	"github.com/iamsalnikov/mymigrate/migrationtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrator_Validate(t *testing.T) {
+	noop := func(db *sql.DB) error { return nil }
+
+	testCases := map[string]struct {
+		add func(m *Migrator)
+
+		expectErr string
+	}{
+		"valid migrations": {
+			add: func(m *Migrator) {
+				m.Add("mig_001", noop, noop)
+				m.AddTx("mig_002", func(tx *sql.Tx) error { return nil }, nil)
+			},
+		},
+		"empty name": {
+			add: func(m *Migrator) {
+				m.Add("", noop, noop)
+			},
+			expectErr: "invalid migrations: migration name is empty",
+		},
+		"too long name": {
+			add: func(m *Migrator) {
+				m.Add(strings.Repeat("a", 501), noop, noop)
+			},
+			expectErr: "invalid migrations: migration name '" + strings.Repeat("a", 32) + "...' is longer than 500 characters",
+		},
+		"long name of multibyte characters": {
+			add: func(m *Migrator) {
+				m.Add(strings.Repeat("я", 500), noop, noop)
+			},
+		},
+		"no up function": {
+			add: func(m *Migrator) {
+				m.Add("mig_001", nil, noop)
+			},
+			expectErr: "invalid migrations: migration 'mig_001' has no up function",
+		},
+		"duplicate of SQL migration": {
+			add: func(m *Migrator) {
+				m.Add("mig_001", noop, noop)
+				_ = m.AddFS(fstest.MapFS{"mig_001.up.sql": {Data: []byte("SELECT 1;")}}, ".")
+			},
+			expectErr: "invalid migrations: migration 'mig_001' is registered more than once",
+		},
+		"every problem": {
+			add: func(m *Migrator) {
+				m.Add("mig_001", noop, noop)
+				m.Add("mig_001", noop, noop)
+				m.Add("mig_002", nil, nil, DependsOn("mig_003"))
+			},
+			expectErr: "invalid migrations: migration 'mig_001' is registered more than once; " +
+				"migration 'mig_002' has no up function; migration 'mig_002' depends on unregistered migration 'mig_003'",
+		},
+	}
+
+	for tcName, tc := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			m := NewMigrator(nil)
+			tc.add(m)
+
+			err := m.Validate()
+			if len(tc.expectErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.EqualError(t, err, tc.expectErr)
+			assert.True(t, errors.Is(err, ErrInvalidMigrations))
+		})
+	}
+}
+
+func TestMigrator_AddKeepsFirstDuplicate(t *testing.T) {
+	calls := make([]string, 0)
+	m := NewMigrator(nil)
+	m.Add("mig_001", func(db *sql.DB) error {
+		calls = append(calls, "first")
+		return nil
+	}, nil)
+	m.Add("mig_001", func(db *sql.DB) error {
+		calls = append(calls, "second")
+		return nil
+	}, nil)
+
+	assert.NoError(t, m.migrations["mig_001"].up(nil, nil))
+	assert.Equal(t, []string{"first"}, calls)
+}
+
+func TestMigrator_RunsRefuseInvalidMigrations(t *testing.T) {
+	testCases := map[string]func(m *Migrator) error{
+		"apply": func(m *Migrator) error {
+			_, err := m.Apply()
+			return err
+		},
+		"apply to": func(m *Migrator) error {
+			_, err := m.ApplyTo("mig_001")
+			return err
+		},
+		"down": func(m *Migrator) error {
+			_, err := m.Down(1)
+			return err
+		},
+		"down to": func(m *Migrator) error {
+			_, err := m.DownTo("mig_001")
+			return err
+		},
+		"redo": func(m *Migrator) error {
+			_, _, err := m.Redo(1)
+			return err
+		},
+		"plan": func(m *Migrator) error {
+			_, err := m.Plan()
+			return err
+		},
+		"down plan": func(m *Migrator) error {
+			_, err := m.DownPlan(1)
+			return err
+		},
+		"baseline": func(m *Migrator) error {
+			_, err := m.Baseline("mig_001")
+			return err
+		},
+	}
+
+	for tcName, run := range testCases {
+		t.Run(tcName, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			// the database isn't touched
+			provider := migrationtest.NewMockDbProvider(ctrl)
+
+			m := NewMigrator(provider)
+			m.Add("mig_001", nil, nil)
+
+			err := run(m)
+			assert.True(t, errors.Is(err, ErrInvalidMigrations), "unexpected error %v", err)
+		})
+	}
+}
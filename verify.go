@@ -0,0 +1,64 @@
+package mymigrate
+
+import (
+	"context"
+)
+
+// DriftKind is a kind of difference between an applied migration and the registered one
+type DriftKind string
+
+const (
+	// DriftChecksumMismatch means that the migration was changed after it had been applied
+	DriftChecksumMismatch DriftKind = "checksum mismatch"
+	// DriftNotRegistered means that the applied migration isn't registered anymore
+	DriftNotRegistered DriftKind = "not registered"
+)
+
+// Drift describes an applied migration that differs from the registered one
+type Drift struct {
+	Name string
+	Kind DriftKind
+	// RecordedChecksum is the checksum stored in the history when the migration was applied
+	RecordedChecksum string
+	// Checksum is the checksum of the registered migration. It is empty for not registered migrations
+	Checksum string
+}
+
+// Verify returns drifts between applied and registered migrations
+func (m *Migrator) Verify() ([]Drift, error) {
+	return m.VerifyContext(context.Background())
+}
+
+// VerifyContext returns drifts between applied and registered migrations in the order of the history.
+// Migrations applied without a checksum aren't compared
+func (m *Migrator) VerifyContext(ctx context.Context) ([]Drift, error) {
+	records, err := m.getApplied(ctx, m.provider)
+	if err != nil {
+		return nil, err
+	}
+
+	drifts := make([]Drift, 0)
+	for _, record := range records {
+		mig, ok := m.migrations[record.Name]
+		if !ok {
+			drifts = append(drifts, Drift{
+				Name:             record.Name,
+				Kind:             DriftNotRegistered,
+				RecordedChecksum: record.Checksum,
+			})
+
+			continue
+		}
+
+		if record.Checksum != "" && record.Checksum != mig.checksum {
+			drifts = append(drifts, Drift{
+				Name:             record.Name,
+				Kind:             DriftChecksumMismatch,
+				RecordedChecksum: record.Checksum,
+				Checksum:         mig.checksum,
+			})
+		}
+	}
+
+	return drifts, nil
+}
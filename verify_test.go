@@ -0,0 +1,33 @@
+package mymigrate
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrator_Verify(t *testing.T) {
+	noop := func(db *sql.DB) error { return nil }
+
+	m := NewMigrator(nil)
+	m.Add("mig_001", noop, noop)
+	m.Add("mig_002", noop, noop, WithVersion("v2"))
+	m.Add("mig_003", noop, noop, WithVersion("v1"))
+	m.getApplied = func(ctx context.Context, provider DbProvider) ([]HistoryRecord, error) {
+		return []HistoryRecord{
+			{Name: "mig_004", Checksum: "v1"},
+			{Name: "mig_003", Checksum: "v1"},
+			{Name: "mig_002", Checksum: "v1"},
+			{Name: "mig_001"},
+		}, nil
+	}
+
+	drifts, err := m.Verify()
+	assert.NoError(t, err)
+	assert.EqualValues(t, []Drift{
+		{Name: "mig_004", Kind: DriftNotRegistered, RecordedChecksum: "v1"},
+		{Name: "mig_002", Kind: DriftChecksumMismatch, RecordedChecksum: "v1", Checksum: "v2"},
+	}, drifts)
+}